@@ -0,0 +1,6 @@
+import {
+  to = stablepairer_pair.example
+  identity = {
+    id = "blue"
+  }
+}
@@ -0,0 +1,5 @@
+# The ID is the result to keep, either as a JSON object of keys to values
+terraform import stablepairer_pair.example '{"a":"3","b":"1","c":"2"}'
+
+# or as keys and values joined by = and separated by commas.
+terraform import stablepairer_pair.example a=3,b=1,c=2
@@ -0,0 +1,4 @@
+resource "stablepairer_sensitive_pair" "example" {
+  keys      = ["a", "b", "c"]
+  values_wo = ["token-1", "token-2", "token-3"]
+}
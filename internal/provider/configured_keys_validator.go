@@ -0,0 +1,124 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+var _ resource.ConfigValidator = configuredKeysValidator{}
+
+// configuredKeysValidator checks that the keys locked_keys, approved_moves and reassign_keys refer to are
+// configured, which can only be told once every key is known.
+type configuredKeysValidator struct{}
+
+func (v configuredKeysValidator) Description(ctx context.Context) string {
+	return v.MarkdownDescription(ctx)
+}
+
+func (v configuredKeysValidator) MarkdownDescription(_ context.Context) string {
+	return "The keys in locked_keys, approved_moves and reassign_keys must be configured."
+}
+
+func (v configuredKeysValidator) ValidateResource(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var model pairModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &model)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	configured, ok := model.configuredKeys(ctx)
+	if !ok {
+		return
+	}
+
+	check := func(key string, at path.Path, effect string) {
+		if !configured[key] {
+			resp.Diagnostics.AddAttributeError(
+				at,
+				"Unconfigured Key",
+				fmt.Sprintf("%q is not one of the configured keys, so %s.", key, effect),
+			)
+		}
+	}
+
+	for _, key := range model.LockedKeys.Elements() {
+		if key, ok := key.(types.String); ok && !key.IsUnknown() && !key.IsNull() {
+			check(key.ValueString(), path.Root("locked_keys").AtSetValue(key), "locking it has no effect")
+		}
+	}
+
+	for _, key := range model.ApprovedMoves.Elements() {
+		if key, ok := key.(types.String); ok && !key.IsUnknown() && !key.IsNull() {
+			check(key.ValueString(), path.Root("approved_moves").AtSetValue(key), "approving its moves has no effect")
+		}
+	}
+
+	for key := range model.ReassignKeys.Elements() {
+		check(key, path.Root("reassign_keys").AtMapKey(key), "it cannot be reassigned")
+	}
+}
+
+// configuredKeys returns the keys of the model as they are in result, or false when any of them is not known
+// yet. Keys that cannot be made up, such as by an invalid generator, are reported by ValidateConfig.
+func (m pairModel) configuredKeys(ctx context.Context) (map[string]bool, bool) {
+	keys := make(map[string]bool)
+
+	switch {
+	case !m.KeyGenerator.IsNull():
+		if !fullyKnown(m.KeyGenerator) {
+			return nil, false
+		}
+
+		generated, diags := generate(ctx, m.KeyGenerator, path.Root("key_generator"))
+		if diags.HasError() {
+			return nil, false
+		}
+
+		for _, key := range generated {
+			keys[key] = true
+		}
+	case !m.KeyObjects.IsNull():
+		if m.KeyObjects.IsUnknown() || !fullyKnown(m.KeyAttributes) || m.KeySeparator.IsUnknown() {
+			return nil, false
+		}
+
+		objects, unknown := m.keyObjects()
+		if unknown > 0 {
+			return nil, false
+		}
+
+		for _, object := range objects {
+			keys[object.key] = true
+		}
+	default:
+		if m.Keys.IsUnknown() {
+			return nil, false
+		}
+
+		for _, key := range m.Keys.Elements() {
+			key, ok := key.(basetypes.StringValuable)
+			if !ok || key.IsUnknown() {
+				return nil, false
+			}
+
+			stringValue, diags := key.ToStringValue(ctx)
+			if diags.HasError() {
+				return nil, false
+			}
+
+			keys[stringValue.ValueString()] = true
+		}
+	}
+
+	return keys, true
+}
@@ -0,0 +1,357 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+// maxGenerated is the most elements a single generator can make up.
+const maxGenerated = 100000
+
+// generatorRangeAttrTypes are the attribute types of each element of the ranges of a generator.
+var generatorRangeAttrTypes = map[string]attr.Type{
+	"format": types.StringType,
+	"from":   types.Int64Type,
+	"to":     types.Int64Type,
+}
+
+// generatorAttrTypes are the attribute types of the key_generator and value_generator attributes.
+var generatorAttrTypes = map[string]attr.Type{
+	"exclude":  types.SetType{ElemType: types.StringType},
+	"patterns": types.ListType{ElemType: types.StringType},
+	"ranges":   types.ListType{ElemType: types.ObjectType{AttrTypes: generatorRangeAttrTypes}},
+}
+
+// generatorModel is the key_generator or value_generator attribute.
+type generatorModel struct {
+	Exclude  types.Set  `tfsdk:"exclude"`
+	Patterns types.List `tfsdk:"patterns"`
+	Ranges   types.List `tfsdk:"ranges"`
+}
+
+// generatorRangeModel is an element of the ranges of a generator.
+type generatorRangeModel struct {
+	Format types.String `tfsdk:"format"`
+	From   types.Int64  `tfsdk:"from"`
+	To     types.Int64  `tfsdk:"to"`
+}
+
+// generatorAttribute returns the schema of a generator of the named elements, which replaces the attribute
+// given by instead.
+func generatorAttribute(elements, instead string) schema.SingleNestedAttribute {
+	return schema.SingleNestedAttribute{
+		Description: fmt.Sprintf("Generates the %s instead of listing them in %s, which avoids materializing large sets in configuration. Everything made up by ranges and patterns is used, in that order, leaving out duplicates and anything in exclude.", elements, instead),
+		Optional:    true,
+		Attributes: map[string]schema.Attribute{
+			"exclude": schema.SetAttribute{
+				Description: fmt.Sprintf("The generated %s to leave out.", elements),
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"patterns": schema.ListAttribute{
+				Description: "Patterns whose braces are expanded like a shell does, with `{a,b}` for each of the alternatives and `{1..10}` or `{01..10}` for each number in a range (e.g. `host-{a,b}-{01..20}`).",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"ranges": schema.ListNestedAttribute{
+				Description: "Ranges of numbers, each formatted into an element.",
+				Optional:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"format": schema.StringAttribute{
+							Description: "The format of each number, defaults to `%d` (e.g. `host-%03d`).",
+							Optional:    true,
+						},
+						"from": schema.Int64Attribute{
+							Description: "The first number of the range.",
+							Required:    true,
+						},
+						"to": schema.Int64Attribute{
+							Description: "The last number of the range, which must not be less than from.",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// fullyKnown returns true when value and everything in it is known.
+func fullyKnown(value attr.Value) bool {
+	if value.IsUnknown() {
+		return false
+	}
+
+	var elements []attr.Value
+
+	switch value := value.(type) {
+	case basetypes.ObjectValue:
+		for _, element := range value.Attributes() {
+			elements = append(elements, element)
+		}
+	case basetypes.ListValue:
+		elements = value.Elements()
+	case basetypes.SetValue:
+		elements = value.Elements()
+	case basetypes.MapValue:
+		for _, element := range value.Elements() {
+			elements = append(elements, element)
+		}
+	}
+
+	for _, element := range elements {
+		if !fullyKnown(element) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// generate returns the elements made up by the generator at path, in the order they are generated leaving out
+// duplicates and excluded elements. It assumes the generator is fully known.
+func generate(ctx context.Context, generator types.Object, at path.Path) ([]string, diag.Diagnostics) {
+	var diagnostics diag.Diagnostics
+
+	var model generatorModel
+	diagnostics.Append(generator.As(ctx, &model, basetypes.ObjectAsOptions{})...)
+	if diagnostics.HasError() {
+		return nil, diagnostics
+	}
+
+	var ranges []generatorRangeModel
+	diagnostics.Append(model.Ranges.ElementsAs(ctx, &ranges, false)...)
+	if diagnostics.HasError() {
+		return nil, diagnostics
+	}
+
+	var generated []string
+
+	for i, r := range ranges {
+		format := "%d"
+		if !r.Format.IsNull() {
+			format = r.Format.ValueString()
+		}
+
+		if !validTemplate(format) {
+			diagnostics.AddAttributeError(
+				at.AtName("ranges").AtListIndex(i).AtName("format"),
+				"Invalid Range Format",
+				fmt.Sprintf("%q must include the number being formatted once, for example with %%d.", format),
+			)
+			continue
+		}
+
+		if r.To.ValueInt64() < r.From.ValueInt64() {
+			diagnostics.AddAttributeError(
+				at.AtName("ranges").AtListIndex(i).AtName("to"),
+				"Invalid Range",
+				fmt.Sprintf("The range ends at %d, which is before it starts at %d.", r.To.ValueInt64(), r.From.ValueInt64()),
+			)
+			continue
+		}
+
+		// The size is taken unsigned as it does not fit an int64 for ranges spanning most of it.
+		if uint64(r.To.ValueInt64())-uint64(r.From.ValueInt64()) >= uint64(maxGenerated-len(generated)) {
+			diagnostics.AddAttributeError(
+				at.AtName("ranges").AtListIndex(i),
+				"Too Many Generated Elements",
+				fmt.Sprintf("A generator can make up at most %d elements.", maxGenerated),
+			)
+			return nil, diagnostics
+		}
+
+		for number := r.From.ValueInt64(); ; number++ {
+			generated = append(generated, fmt.Sprintf(format, number))
+
+			if number == r.To.ValueInt64() {
+				break
+			}
+		}
+	}
+
+	for i, pattern := range model.Patterns.Elements() {
+		pattern, ok := pattern.(types.String)
+		if !ok || pattern.IsNull() {
+			continue
+		}
+
+		expanded, err := expand(pattern.ValueString(), maxGenerated-len(generated))
+		if err != nil {
+			diagnostics.AddAttributeError(
+				at.AtName("patterns").AtListIndex(i),
+				"Invalid Pattern",
+				fmt.Sprintf("The pattern %q cannot be expanded: %s.", pattern.ValueString(), err),
+			)
+			continue
+		}
+
+		generated = append(generated, expanded...)
+	}
+
+	excluded := stringSet(model.Exclude)
+	seen := make(map[string]bool, len(generated))
+
+	elements := make([]string, 0, len(generated))
+	for _, element := range generated {
+		if excluded[element] || seen[element] {
+			continue
+		}
+
+		seen[element] = true
+		elements = append(elements, element)
+	}
+
+	return elements, diagnostics
+}
+
+// numberRange matches the body of braces that make up a range of numbers.
+var numberRange = regexp.MustCompile(`^(-?[0-9]+)\.\.(-?[0-9]+)$`)
+
+// expand returns the expansion of the braces in pattern like a shell does, failing if they are unbalanced, empty
+// or make up more than limit elements.
+func expand(pattern string, limit int) ([]string, error) {
+	start, end, depth := -1, -1, 0
+
+	for i, c := range pattern {
+		if c == '{' {
+			if depth == 0 {
+				start = i
+			}
+
+			depth += 1
+		}
+
+		if c == '}' {
+			if depth == 0 {
+				return nil, fmt.Errorf("the brace at %d is never opened", i)
+			}
+
+			depth -= 1
+
+			if depth == 0 {
+				end = i
+				break
+			}
+		}
+	}
+
+	if depth > 0 {
+		return nil, fmt.Errorf("the brace at %d is never closed", start)
+	}
+
+	if start < 0 {
+		return []string{pattern}, nil
+	}
+
+	prefix, body, suffix := pattern[:start], pattern[start+1:end], pattern[end+1:]
+
+	alternatives, err := alternativesOf(body, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	suffixes, err := expand(suffix, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var expanded []string
+
+	for _, alternative := range alternatives {
+		alternativeExpansions, err := expand(alternative, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, alternativeExpansion := range alternativeExpansions {
+			for _, suffix := range suffixes {
+				if len(expanded) >= limit {
+					return nil, fmt.Errorf("it makes up more than %d elements", limit)
+				}
+
+				expanded = append(expanded, prefix+alternativeExpansion+suffix)
+			}
+		}
+	}
+
+	return expanded, nil
+}
+
+// alternativesOf returns what the body of a pair of braces stands for, which is either each of its comma
+// separated alternatives or each of the numbers in its range, padded with zeros when either end is.
+func alternativesOf(body string, limit int) ([]string, error) {
+	if match := numberRange.FindStringSubmatch(body); match != nil {
+		from, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := strconv.Atoi(match[2])
+		if err != nil {
+			return nil, err
+		}
+
+		step, span := 1, uint64(to)-uint64(from)
+		if to < from {
+			step, span = -1, uint64(from)-uint64(to)
+		}
+
+		if span >= uint64(limit) {
+			return nil, fmt.Errorf("it makes up more than %d elements", limit)
+		}
+
+		width := 0
+		for _, end := range match[1:] {
+			if digits := strings.TrimPrefix(end, "-"); len(digits) > 1 && digits[0] == '0' {
+				width = max(width, len(end))
+			}
+		}
+
+		var numbers []string
+		for number := from; ; number += step {
+			numbers = append(numbers, fmt.Sprintf("%0*d", width, number))
+
+			if number == to {
+				break
+			}
+		}
+
+		return numbers, nil
+	}
+
+	var alternatives []string
+	depth, last := 0, 0
+
+	for i, c := range body {
+		switch {
+		case c == '{':
+			depth += 1
+		case c == '}':
+			depth -= 1
+		case c == ',' && depth == 0:
+			alternatives = append(alternatives, body[last:i])
+			last = i + 1
+		}
+	}
+
+	if len(alternatives) == 0 {
+		return nil, fmt.Errorf("{%s} is neither a list of alternatives nor a range", body)
+	}
+
+	return append(alternatives, body[last:]), nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestExpand(t *testing.T) {
+	var tests = []struct {
+		pattern  string
+		limit    int
+		expanded []string
+		err      bool
+	}{
+		{pattern: "host", limit: 10, expanded: []string{"host"}},
+		{pattern: "host-{a,b}", limit: 10, expanded: []string{"host-a", "host-b"}},
+		{pattern: "{a,b}-{1..2}", limit: 10, expanded: []string{"a-1", "a-2", "b-1", "b-2"}},
+		{pattern: "host-{08..10}", limit: 10, expanded: []string{"host-08", "host-09", "host-10"}},
+		{pattern: "host-{3..1}", limit: 10, expanded: []string{"host-3", "host-2", "host-1"}},
+		{pattern: "{a,b{1,2}}", limit: 10, expanded: []string{"a", "b1", "b2"}},
+		{pattern: "{a,}", limit: 10, expanded: []string{"a", ""}},
+		{pattern: "host-{1..11}", limit: 10, err: true},
+		{pattern: "{a,b}{c,d}{e,f}", limit: 7, err: true},
+		{pattern: "{-9223372036854775808..9223372036854775807}", limit: 10, err: true},
+		{pattern: "{9223372036854775807..-9223372036854775808}", limit: 10, err: true},
+		{pattern: "host-{a,b", limit: 10, err: true},
+		{pattern: "host-a}", limit: 10, err: true},
+		{pattern: "host-{a}", limit: 10, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			expanded, err := expand(test.pattern, test.limit)
+
+			if test.err {
+				if err == nil {
+					t.Errorf("Got %q, wanted an error", expanded)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %s", err)
+			}
+
+			if !reflect.DeepEqual(expanded, test.expanded) {
+				t.Errorf("Got %q, wanted %q", expanded, test.expanded)
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	strings := func(elements ...string) []attr.Value {
+		values := make([]attr.Value, 0, len(elements))
+		for _, element := range elements {
+			values = append(values, types.StringValue(element))
+		}
+
+		return values
+	}
+
+	generator := func(ranges []attr.Value, patterns []attr.Value, exclude []attr.Value) types.Object {
+		return types.ObjectValueMust(generatorAttrTypes, map[string]attr.Value{
+			"exclude":  types.SetValueMust(types.StringType, exclude),
+			"patterns": types.ListValueMust(types.StringType, patterns),
+			"ranges":   types.ListValueMust(types.ObjectType{AttrTypes: generatorRangeAttrTypes}, ranges),
+		})
+	}
+
+	numbers := func(format types.String, from, to int64) attr.Value {
+		return types.ObjectValueMust(generatorRangeAttrTypes, map[string]attr.Value{
+			"format": format,
+			"from":   types.Int64Value(from),
+			"to":     types.Int64Value(to),
+		})
+	}
+
+	var tests = []struct {
+		name      string
+		generator types.Object
+		generated []string
+		errorPath path.Path
+	}{
+		{
+			name:      "ranges",
+			generator: generator([]attr.Value{numbers(types.StringValue("host-%03d"), 1, 3), numbers(types.StringNull(), 9, 10)}, nil, nil),
+			generated: []string{"host-001", "host-002", "host-003", "9", "10"},
+		},
+		{
+			name:      "patterns",
+			generator: generator(nil, strings("{a,b}-{1..2}", "c"), nil),
+			generated: []string{"a-1", "a-2", "b-1", "b-2", "c"},
+		},
+		{
+			name:      "duplicates and exclusions",
+			generator: generator([]attr.Value{numbers(types.StringValue("host-%d"), 1, 4)}, strings("host-{3..6}"), strings("host-2", "host-5")),
+			generated: []string{"host-1", "host-3", "host-4", "host-6"},
+		},
+		{
+			name:      "invalid format",
+			generator: generator([]attr.Value{numbers(types.StringValue("host"), 1, 4)}, nil, nil),
+			errorPath: path.Root("value_generator").AtName("ranges").AtListIndex(0).AtName("format"),
+		},
+		{
+			name:      "invalid range",
+			generator: generator([]attr.Value{numbers(types.StringNull(), 1, 4), numbers(types.StringNull(), 4, 1)}, nil, nil),
+			errorPath: path.Root("value_generator").AtName("ranges").AtListIndex(1).AtName("to"),
+		},
+		{
+			name:      "too many",
+			generator: generator([]attr.Value{numbers(types.StringNull(), 1, maxGenerated+1)}, nil, nil),
+			errorPath: path.Root("value_generator").AtName("ranges").AtListIndex(0),
+		},
+		{
+			name:      "range ending at the limit",
+			generator: generator([]attr.Value{numbers(types.StringNull(), math.MaxInt64-1, math.MaxInt64)}, nil, nil),
+			generated: []string{"9223372036854775806", "9223372036854775807"},
+		},
+		{
+			name:      "range spanning the limits",
+			generator: generator([]attr.Value{numbers(types.StringNull(), math.MinInt64, math.MaxInt64)}, nil, nil),
+			errorPath: path.Root("value_generator").AtName("ranges").AtListIndex(0),
+		},
+		{
+			name:      "invalid pattern",
+			generator: generator(nil, strings("a", "{b"), nil),
+			errorPath: path.Root("value_generator").AtName("patterns").AtListIndex(1),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			generated, diags := generate(context.Background(), test.generator, path.Root("value_generator"))
+
+			if len(test.errorPath.Steps()) == 0 {
+				if diags.HasError() {
+					t.Fatalf("Got errors %v", diags)
+				}
+
+				if !reflect.DeepEqual(generated, test.generated) {
+					t.Errorf("Got %q, wanted %q", generated, test.generated)
+				}
+				return
+			}
+
+			if len(diags.Errors()) != 1 {
+				t.Fatalf("Got errors %v, wanted one at %s", diags, test.errorPath)
+			}
+
+			if withPath, ok := diags.Errors()[0].(interface{ Path() path.Path }); !ok || !withPath.Path().Equal(test.errorPath) {
+				t.Errorf("Got error %v, wanted one at %s", diags.Errors()[0], test.errorPath)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Normalization modes which decide when two keys or two values are considered the same.
+const (
+	normalizationNone     = "none"
+	normalizationIP       = "ip"
+	normalizationHostname = "hostname"
+	normalizationAuto     = "auto"
+)
+
+var normalizations = []string{
+	normalizationNone,
+	normalizationIP,
+	normalizationHostname,
+	normalizationAuto,
+}
+
+// normalize returns the canonical form of s in the given mode. The ip mode rewrites IP addresses and prefixes
+// into their shortest form, the hostname mode lowercases and drops any trailing dot and the auto mode applies
+// the former to anything that is an IP address and the latter to everything else.
+func normalize(mode, s string) string {
+	switch mode {
+	case normalizationIP:
+		if ip, ok := normalizeIP(s); ok {
+			return ip
+		}
+	case normalizationHostname:
+		return normalizeHostname(s)
+	case normalizationAuto:
+		if ip, ok := normalizeIP(s); ok {
+			return ip
+		}
+
+		return normalizeHostname(s)
+	}
+
+	return s
+}
+
+func normalizeHostname(s string) string {
+	return strings.TrimSuffix(strings.ToLower(s), ".")
+}
+
+func normalizeIP(s string) (string, bool) {
+	if addr, bits, ok := strings.Cut(s, "/"); ok {
+		ones, err := strconv.Atoi(bits)
+		if err != nil || ones < 0 {
+			return "", false
+		}
+
+		addr, ok := normalizeIP(addr)
+		if !ok {
+			return "", false
+		}
+
+		prefix, err := netip.ParsePrefix(fmt.Sprintf("%s/%d", addr, ones))
+		if err != nil {
+			return "", false
+		}
+
+		return prefix.String(), true
+	}
+
+	// Dotted decimal octets are parsed by hand as netip rejects leading zeros.
+	if octets := strings.Split(s, "."); len(octets) == 4 {
+		var ip [4]byte
+
+		for i, octet := range octets {
+			if octet == "" || strings.Trim(octet, "0123456789") != "" {
+				return "", false
+			}
+
+			value, err := strconv.ParseUint(octet, 10, 8)
+			if err != nil {
+				return "", false
+			}
+
+			ip[i] = byte(value)
+		}
+
+		return netip.AddrFrom4(ip).String(), true
+	}
+
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", false
+	}
+
+	return ip.String(), true
+}
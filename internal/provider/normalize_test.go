@@ -0,0 +1,40 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	var tests = []struct {
+		mode, value, normalized string
+	}{
+		{normalizationNone, "10.000.0.1", "10.000.0.1"},
+		{normalizationNone, "Example.com", "Example.com"},
+		{normalizationIP, "10.000.0.1", "10.0.0.1"},
+		{normalizationIP, "010.0.0.001", "10.0.0.1"},
+		{normalizationIP, "10.0.0.256", "10.0.0.256"},
+		{normalizationIP, "10.0.0.0/08", "10.0.0.0/8"},
+		{normalizationIP, "2001:DB8:0:0:0:0:0:1", "2001:db8::1"},
+		{normalizationIP, "2001:0db8::0001", "2001:db8::1"},
+		{normalizationIP, "2001:db8::/032", "2001:db8::/32"},
+		{normalizationIP, "Example.com", "Example.com"},
+		{normalizationHostname, "Example.COM.", "example.com"},
+		{normalizationHostname, "10.000.0.1", "10.000.0.1"},
+		{normalizationAuto, "10.000.0.1", "10.0.0.1"},
+		{normalizationAuto, "Example.com.", "example.com"},
+	}
+
+	for _, test := range tests {
+		testname := fmt.Sprintf("%s,%s", test.mode, test.value)
+
+		t.Run(testname, func(t *testing.T) {
+			if actual := normalize(test.mode, test.value); actual != test.normalized {
+				t.Errorf("Got %q, wanted %q", actual, test.normalized)
+			}
+		})
+	}
+}
@@ -46,6 +46,7 @@ func (p *StablePairer) Metadata(ctx context.Context, req provider.MetadataReques
 func (p *StablePairer) Resources(ctx context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewPairResource,
+		NewSensitivePairResource,
 	}
 }
 
@@ -4,20 +4,48 @@
 package provider
 
 import (
+	"container/heap"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework-validators/boolvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/listvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/mapvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/resourcevalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/setvalidator"
+	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/identityschema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
-	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
-	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 )
 
-var _ resource.ResourceWithModifyPlan = (*PairResource)(nil)
+var (
+	_ resource.ResourceWithConfigValidators = (*PairResource)(nil)
+	_ resource.ResourceWithIdentity         = (*PairResource)(nil)
+	_ resource.ResourceWithImportState      = (*PairResource)(nil)
+	_ resource.ResourceWithModifyPlan       = (*PairResource)(nil)
+	_ resource.ResourceWithMoveState        = (*PairResource)(nil)
+	_ resource.ResourceWithUpgradeState     = (*PairResource)(nil)
+	_ resource.ResourceWithValidateConfig   = (*PairResource)(nil)
+)
 
 type PlanOrState interface {
 	Set(context.Context, interface{}) diag.Diagnostics
@@ -29,6 +57,27 @@ func NewPairResource() resource.Resource {
 
 type PairResource struct{}
 
+func (r *PairResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		resourcevalidator.ExactlyOneOf(
+			path.MatchRoot("keys"),
+			path.MatchRoot("key_objects"),
+			path.MatchRoot("key_generator"),
+		),
+		resourcevalidator.ExactlyOneOf(
+			path.MatchRoot("values"),
+			path.MatchRoot("value_objects"),
+			path.MatchRoot("value_tiers"),
+			path.MatchRoot("value_generator"),
+		),
+		resourcevalidator.RequiredTogether(
+			path.MatchRoot("key_objects"),
+			path.MatchRoot("key_attributes"),
+		),
+		configuredKeysValidator{},
+	}
+}
+
 func (r *PairResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
 	var model pairModel
 
@@ -39,9 +88,9 @@ func (r *PairResource) Create(ctx context.Context, req resource.CreateRequest, r
 		return
 	}
 
-	model.ID = types.StringValue("-")
+	r.apply(ctx, model, model.initialPrior(), timestamp(), &resp.Diagnostics, &resp.State)
 
-	r.modify(ctx, model, map[string]string{}, &resp.Diagnostics, &resp.State)
+	identify(ctx, resp.State, resp.Identity, &resp.Diagnostics)
 }
 
 // Delete does not need to explicitly call resp.State.RemoveResource() as this is automatically handled by the
@@ -49,8 +98,115 @@ func (r *PairResource) Create(ctx context.Context, req resource.CreateRequest, r
 func (r *PairResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
 }
 
+// ImportState seeds the result from an ID that is either a JSON object of keys to values or keys and values
+// joined by `=` and separated by commas. Only the result and what tracks it are set, the next plan pairs the
+// configured keys and values against it.
+//
+// An identity carries no result, so importing by identity only sets the id and the next plan pairs every key from
+// scratch.
+func (r *PairResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	if req.ID == "" {
+		resource.ImportStatePassthroughWithIdentity(ctx, path.Root("id"), path.Root("id"), req, resp)
+		return
+	}
+
+	result, err := parseImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid Import ID",
+			fmt.Sprintf("The ID must be a JSON object of keys to values, such as {\"a\":\"1\"}, or keys and values joined by = and separated by commas, such as a=1,b=2: %s.", err),
+		)
+		return
+	}
+
+	seedState(ctx, result, &resp.State, &resp.Diagnostics)
+
+	identify(ctx, resp.State, resp.Identity, &resp.Diagnostics)
+}
+
+// seedState sets the result in a state that has nothing else set, along with what tracks it, for the next plan
+// to pair the configured keys and values against.
+func seedState(ctx context.Context, result map[string]string, state *tfsdk.State, diagnostics *diag.Diagnostics) {
+	mapping := make(map[string]attr.Value, len(result))
+	for key, value := range result {
+		mapping[key] = types.StringValue(value)
+	}
+
+	// The result is the first generation, just like a result that predates generations.
+	p := pairing{mapping: mapping}
+	summary := p.summary(map[string]string{})
+	metadata, generation := p.metadata(priorPairing{}, types.StringNull())
+	history := priorPairing{history: []historyEntry{}}.historyValue(&historyEntry{
+		generation: generation.ValueInt64(),
+		result:     result,
+	}, defaultHistorySize)
+
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("id"), types.StringValue(resultHash(result)))...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("result"), types.MapValueMust(types.StringType, mapping))...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("assignment_metadata"), metadata)...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("generation"), generation)...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("history"), history)...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("history_size"), types.Int64Value(defaultHistorySize))...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("added_keys"), summary.addedKeys)...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("churn_ratio"), summary.churnRatio)...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("freed_values"), summary.freedValues)...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("reassigned"), summary.reassigned)...)
+	diagnostics.Append(state.SetAttribute(ctx, path.Root("removed_keys"), summary.removedKeys)...)
+}
+
+// parseImportID returns the result encoded in an import ID.
+func parseImportID(id string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	if strings.HasPrefix(strings.TrimSpace(id), "{") {
+		if err := json.Unmarshal([]byte(id), &result); err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	}
+
+	if strings.TrimSpace(id) == "" {
+		return nil, fmt.Errorf("the ID is empty")
+	}
+
+	for _, entry := range strings.Split(id, ",") {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is missing an =", entry)
+		}
+
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" {
+			return nil, fmt.Errorf("%q is missing a key", entry)
+		}
+
+		if _, ok := result[key]; ok {
+			return nil, fmt.Errorf("the key %q is given more than once", key)
+		}
+
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+func (r *PairResource) IdentitySchema(_ context.Context, req resource.IdentitySchemaRequest, resp *resource.IdentitySchemaResponse) {
+	resp.IdentitySchema = identityschema.Schema{
+		Attributes: map[string]identityschema.Attribute{
+			"id": identityschema.StringAttribute{
+				Description:       "The id of the pair, which is its name or, when it has none, the hash of its first result.",
+				RequiredForImport: true,
+			},
+		},
+	}
+}
+
 func (r *PairResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
 	resp.TypeName = req.ProviderTypeName + "_pair"
+
+	// Naming a pair, or renaming it, changes its identity without replacing it.
+	resp.ResourceBehavior.MutableIdentity = true
 }
 
 func (r *PairResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
@@ -68,61 +224,545 @@ func (r *PairResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRe
 		return
 	}
 
-	// Read existing result field from state, if present.
-	existingResult := make(map[string]types.String)
+	// Read existing result from state, if present, or else adopt the initial result.
+	prior := model.initialPrior()
 	if !req.State.Raw.IsNull() {
-		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("result"), &existingResult)...)
+		var state pairModel
+		resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 
 		if resp.Diagnostics.HasError() {
 			return
 		}
+
+		prior = state.prior(model)
+
+		// A state that was just imported or moved has its result adopted like initial_result.
+		prior.adopting = state.imported()
 	}
 
-	convertedExistingResult := make(map[string]string, len(existingResult))
-	for key, value := range existingResult {
-		convertedExistingResult[key] = value.ValueString()
+	r.modify(ctx, model, prior, types.StringUnknown(), &resp.Diagnostics, &resp.Plan)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	r.modify(ctx, model, convertedExistingResult, &resp.Diagnostics, &resp.Plan)
+	identify(ctx, resp.Plan, resp.Identity, &resp.Diagnostics)
+
+	// Rather than planning an entirely unknown result, clients that support it are asked to defer the change
+	// until the keys are known. Older clients keep getting the unknown result.
+	if req.ClientCapabilities.DeferralAllowed {
+		var result types.Map
+		resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("result"), &result)...)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+
+		if result.IsUnknown() {
+			resp.Deferred = &resource.Deferred{
+				Reason: resource.DeferredReasonResourceConfigUnknown,
+			}
+		}
+	}
 }
 
-// Read does not need to perform any operations as the state in ReadResourceResponse is already populated.
+// Read does not need to perform any operations as the state in ReadResourceResponse is already populated, other
+// than setting the identity of states that predate it.
 func (r *PairResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	identify(ctx, resp.State, resp.Identity, &resp.Diagnostics)
 }
 
 func (r *PairResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
-		MarkdownDescription: "Generates a mapping of keys to values that stays stable between applies and makes minimal changes when the set of keys or values changes.",
+		Version:             2,
+		MarkdownDescription: "Generates a mapping of keys to values that stays stable between applies and makes minimal changes when the set of keys or values changes. A `moved` block can move a `random_shuffle`, whose positions become keys from `0` on, or a `terraform_data` whose output is a map of strings into this resource, keeping the mapping as its result.",
 		Attributes: map[string]schema.Attribute{
+			"approved_moves": schema.SetAttribute{
+				Description: "The keys that may be reassigned when change_policy is `approved_only`.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"change_policy": schema.StringAttribute{
+				Description: "Whether existing assignments may change. One of `allow` (the default) to reassign keys as needed, `strict` to fail the plan when any key that is still configured would lose or change its value or `approved_only` to only allow that for keys in approved_moves. Keys that are no longer configured can always be removed.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(changePolicies...),
+				},
+			},
+			"initial_result": schema.MapAttribute{
+				Description: "A mapping of keys to values to adopt as the prior result when the resource is created, such as one maintained by hand until now, so that those assignments are kept rather than made from scratch. Entries for keys or values that are not configured, or that cannot all be kept, are left out with a warning. Changing this after the resource is created has no effect.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"keepers": schema.MapAttribute{
+				Description: "Arbitrary map of values that, when changed, will discard the prior result and pair every key from scratch. change_policy and locked_keys still apply.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"key_attributes": schema.ListAttribute{
+				Description: "The names of the identity attributes of key_objects, in the order they are joined to make up the keys. Required with key_objects.",
+				ElementType: types.StringType,
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.SizeAtLeast(1),
+				},
+			},
+			"key_generator": generatorAttribute("keys", "keys"),
+			"key_objects": schema.SetAttribute{
+				Description: "A set of objects of arbitrary string attributes to assign a value instead of keys. Each object is identified by the values of its key_attributes joined by key_separator, which is the key it gets in result. Assignments are tracked by those identity attribute values, so changing key_separator does not move anything. Exactly one of keys, key_objects or key_generator must be set.",
+				ElementType: types.MapType{ElemType: types.StringType},
+				Optional:    true,
+				Validators: []validator.Set{
+					setvalidator.NoNullValues(),
+					setvalidator.SizeAtMost(maxElements),
+				},
+			},
+			"key_pattern": schema.StringAttribute{
+				Description: "A regular expression, in RE2 syntax, that every key must match (e.g. `^[a-z0-9-]+$`), including the keys made up by key_objects or key_generator.",
+				Optional:    true,
+			},
+			"key_separator": schema.StringAttribute{
+				Description: "The separator used to join the identity attributes of key_objects, defaults to `/`.",
+				Optional:    true,
+			},
 			"keys": schema.SetAttribute{
-				Description: "The set of keys to assign a value. An unknown key that can be assigned a value (either known or unknown) will trigger the result to be unknown.",
+				Description: fmt.Sprintf("The set of keys to assign a value, at most %d of them and none of them empty. An unknown key that can be assigned a value (either known or unknown) will trigger the result to be unknown, or the change to be deferred when Terraform supports deferred actions. Exactly one of keys, key_objects or key_generator must be set.", maxElements),
+				ElementType: types.StringType,
+				Optional:    true,
+				Validators:  elementValidators(),
+			},
+			"history_size": schema.Int64Attribute{
+				Computed:    true,
+				Default:     int64default.StaticInt64(defaultHistorySize),
+				Description: fmt.Sprintf("The number of the most recent results to keep in history, defaults to %d.", defaultHistorySize),
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(0),
+				},
+			},
+			"locality_attributes": schema.ListAttribute{
+				Description: "The attributes of value_objects, broadest first (e.g. zone, subnet then rack), to keep when a key loses its value because it was removed. The key is then assigned a free value that shares as many of these attributes with the removed one as possible, in order, before falling back to any free value. The attributes of the removed value are taken from value_objects in the prior state. The result is unknown while any of these attributes of value_objects is.",
+				ElementType: types.StringType,
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.AlsoRequires(path.MatchRoot("value_objects")),
+					listvalidator.SizeAtLeast(1),
+					listvalidator.UniqueValues(),
+				},
+			},
+			"locked_keys": schema.SetAttribute{
+				Description: "The keys that must keep their value whatever the change_policy, so that a plan that would reassign one of them fails instead. To move a locked key, remove it from locked_keys first.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"migrate_to_higher_tiers": schema.BoolAttribute{
+				Description: "When true, keys assigned values in lower tiers of value_tiers are moved to values with room in higher tiers, up to tier_migration_max_moves per apply. Only keys that change_policy and locked_keys allow to move are moved and nothing is moved while keys or values are unknown.",
+				Optional:    true,
+				Validators: []validator.Bool{
+					boolvalidator.AlsoRequires(path.MatchRoot("value_tiers")),
+				},
+			},
+			"max_keys_per_value": schema.Int64Attribute{
+				Description: "The number of keys each value can be assigned to, defaults to 1. New keys are assigned the least used value that has room.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"name": schema.StringAttribute{
+				Description: "A name that identifies the pair, used as its id and identity. Changing it changes both without changing result.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.LengthAtLeast(1),
+				},
+			},
+			"normalization": schema.StringAttribute{
+				Description: "How keys and values are compared, so that formatting changes do not move assignments. One of `none` (the default) to compare them as is, `ip` to compare IP addresses and prefixes by their canonical form (e.g. `10.000.0.1` is `10.0.0.1`), `hostname` to compare case-insensitively ignoring any trailing dot or `auto` to use `ip` for IP addresses and `hostname` for everything else. Keys and values that are the same once normalized are not allowed. Respelling a key or value still shows as an update, as `result` takes the new spelling, but moves no assignment and changes no `generation`. Semantic equality is deliberately not provided for keys and values: Terraform only checks it between a plan and the applied state or between the prior and the refreshed state, and configured keys and values never change there.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(normalizations...),
+				},
+			},
+			"value_generator": generatorAttribute("values", "values"),
+			"value_objects": schema.MapAttribute{
+				Description: "A map of value IDs to objects of arbitrary string attributes (e.g. ip, zone and port) to assign to keys instead of values. Keys are paired with the value IDs, so changing the attributes of a value does not move it to a different key. Exactly one of values, value_objects, value_tiers or value_generator must be set.",
+				ElementType: types.MapType{ElemType: types.StringType},
+				Optional:    true,
+				Validators: []validator.Map{
+					mapvalidator.NoNullValues(),
+					mapvalidator.SizeAtMost(maxElements),
+					mapvalidator.KeysAre(stringvalidator.LengthAtLeast(1)),
+				},
+			},
+			"value_pattern": schema.StringAttribute{
+				Description: "A regular expression, in RE2 syntax, that every value must match (e.g. `^10\\.`), including the IDs of value_objects and the values in value_tiers or made up by value_generator. Values generated for overflow are not checked.",
+				Optional:    true,
+			},
+			"value_tiers": schema.ListAttribute{
+				Description: "Ordered tiers of values to assign to keys instead of values, highest first (e.g. reserved capacity before on-demand capacity). New keys are assigned a value in the highest tier that has room, while existing keys keep their value whatever its tier unless migrate_to_higher_tiers is set. A value can only be in one tier. Exactly one of values, value_objects, value_tiers or value_generator must be set.",
+				ElementType: types.SetType{ElemType: types.StringType},
+				Optional:    true,
+				Validators: []validator.List{
+					listvalidator.ValueSetsAre(elementValidators()...),
+				},
+			},
+			"reassign_keys": schema.MapAttribute{
+				Description: "A map of keys to arbitrary nonces. Whenever the nonce of a key is added or changed, that key is moved to a different free value without moving any other key, which fails if there is no free value. Such moves are allowed whatever the change_policy, though not for locked_keys.",
 				ElementType: types.StringType,
-				Required:    true,
+				Optional:    true,
+			},
+			"overflow": schema.StringAttribute{
+				Description: "What to do with keys that there are not enough values for. One of `leave_unassigned` (the default) to leave them out of result, `error` to fail instead, `warn` to leave them out with a warning, `share` to raise max_keys_per_value as far as needed to spread keys evenly across values or `generate` to add as many values made from overflow_template as needed. Any keys left out are listed in unassigned_keys.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.OneOf(overflows...),
+				},
+			},
+			"overflow_template": schema.StringAttribute{
+				Description: "The format of the values generated when overflow is `generate`, given the number of each generated value starting from one (e.g. `spare-%03d`). Numbers that make up a configured value are skipped.",
+				Optional:    true,
+				Validators: []validator.String{
+					stringvalidator.AlsoRequires(path.MatchRoot("overflow")),
+				},
+			},
+			"rebalance_max_moves": schema.Int64Attribute{
+				Description: "The most keys rebalancing may move in a single apply, unlimited when not set. Any remaining moves are planned on the next apply.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"rebalance_tolerance": schema.Int64Attribute{
+				Description: "When set, keys are moved from the most used values to the least used ones whenever the difference in how many keys they are assigned to is more than this, for example after new values are added. Keys are never moved between tiers of value_tiers. Only keys that change_policy and locked_keys allow to move are moved and nothing is moved while keys or values are unknown.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+				},
+			},
+			"rebalance_trigger": schema.StringAttribute{
+				Description: "An arbitrary value that, when set or changed, rebalances the result as evenly as possible within rebalance_max_moves, whatever rebalance_tolerance is, without pairing every key from scratch like keepers does.",
+				Optional:    true,
+			},
+			"tier_migration_max_moves": schema.Int64Attribute{
+				Description: "The most keys migrate_to_higher_tiers may move in a single apply, unlimited when not set. Any remaining moves are planned on the next apply.",
+				Optional:    true,
+				Validators: []validator.Int64{
+					int64validator.AtLeast(1),
+					int64validator.AlsoRequires(path.MatchRoot("migrate_to_higher_tiers")),
+				},
+			},
+			"rollback_to_generation": schema.Int64Attribute{
+				Description: "A generation in history to return to. When this is set or changed, the result of that generation is restored for every key and value that still exists and the remaining keys are assigned values as usual. The rollback is a change like any other, so it gets a new generation. Leaving this set afterwards has no further effect.",
+				Optional:    true,
 			},
 			"values": schema.SetAttribute{
-				Description: "The set of values to assign to keys.",
+				Description: fmt.Sprintf("The set of values to assign to keys, at most %d of them and none of them empty. Exactly one of values, value_objects, value_tiers or value_generator must be set.", maxElements),
 				ElementType: types.StringType,
-				Required:    true,
+				Optional:    true,
+				Validators:  elementValidators(),
 			},
 
 			// Computed
+			"added_keys": schema.SetAttribute{
+				Computed:    true,
+				Description: "The keys in result that were not in the prior result, as of the most recent change to result. This is unknown whenever result is.",
+				ElementType: types.StringType,
+			},
+			"assignment_metadata": schema.MapNestedAttribute{
+				Computed:    true,
+				Description: "The metadata of each key in result, which is kept for as long as the key keeps its value. This is unknown whenever result is.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"assigned_at": schema.StringAttribute{
+							Computed:    true,
+							Description: "The RFC 3339 timestamp of when the key was assigned its value. Null for assignments made before metadata was tracked.",
+						},
+						"generation": schema.Int64Attribute{
+							Computed:    true,
+							Description: "The generation at which the key was assigned its value.",
+						},
+						"hash": schema.StringAttribute{
+							Computed:    true,
+							Description: "A hash of the key and its value, which only changes when the value does. Suitable for `replace_triggered_by`.",
+						},
+					},
+				},
+			},
+			"assignments": schema.SetNestedAttribute{
+				Computed:    true,
+				Description: "The same mapping as result as a set of objects. Unlike result, this is never entirely unknown at plan time: assignments kept from the prior state stay known, an assignment that depends on an unknown key or value has an unknown value and any key that is not known yet but could be assigned a value is covered by an unknown element.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"key": schema.StringAttribute{
+							Computed:    true,
+							Description: "The key being assigned a value.",
+						},
+						"value": schema.StringAttribute{
+							Computed:    true,
+							Description: "The value assigned to the key.",
+						},
+					},
+				},
+			},
+			"churn_ratio": schema.Float64Attribute{
+				Computed:    true,
+				Description: "The share of keys in either the prior or the new result that were added, removed or reassigned, as of the most recent change to result. This is unknown whenever result is or any value in result is not yet known.",
+			},
+			"freed_values": schema.SetAttribute{
+				Computed:    true,
+				Description: "The values in the prior result that are no longer assigned to any key, as of the most recent change to result. This is unknown whenever result is or any value in result is not yet known.",
+				ElementType: types.StringType,
+			},
+			"generation": schema.Int64Attribute{
+				Computed:    true,
+				Description: "A counter that starts at 1 and increases by one on every change to result. This is unknown whenever result is or any value in result is not yet known.",
+			},
+			"grouped": schema.MapAttribute{
+				Computed:    true,
+				Description: "The mapping of each value in result to the sorted list of keys it is assigned to. This is unknown whenever result is or any value in result is not yet known.",
+				ElementType: types.ListType{ElemType: types.StringType},
+			},
+			"history": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The most recent results by generation, oldest first, up to history_size of them. The last one is the current result. This is unknown whenever generation is.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"generation": schema.Int64Attribute{
+							Computed:    true,
+							Description: "The generation of the result.",
+						},
+						"result": schema.MapAttribute{
+							Computed:    true,
+							Description: "The result as of the generation.",
+							ElementType: types.StringType,
+						},
+					},
+				},
+			},
 			"id": schema.StringAttribute{
 				Computed:    true,
-				Description: "A static value used internally by Terraform, this should not be referenced in configurations.",
-				PlanModifiers: []planmodifier.String{
-					stringplanmodifier.UseStateForUnknown(),
+				Description: "The name or, when there is none, the hash of the first result, which never changes after that. This is also the identity of the pair and is unknown until the first result is fully known.",
+			},
+			"inverse": schema.MapAttribute{
+				Computed:    true,
+				Description: "The mapping of each value in result to the key it is assigned to, or the first of them in sorted order when several keys share a value. This is unknown whenever result is or any value in result is not yet known.",
+				ElementType: types.StringType,
+			},
+			"ordered": schema.ListNestedAttribute{
+				Computed:    true,
+				Description: "The same mapping as result as a list of objects sorted by index. Indexes run from zero to one less than the size of result and are assigned as stably as values are, so an entry keeps its index for as long as its key stays in result and the index is still in range. This is unknown whenever result is.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"index": schema.Int64Attribute{
+							Computed:    true,
+							Description: "The stable position of the entry.",
+						},
+						"key": schema.StringAttribute{
+							Computed:    true,
+							Description: "The key being assigned a value.",
+						},
+						"value": schema.StringAttribute{
+							Computed:    true,
+							Description: "The value assigned to the key.",
+						},
+					},
+				},
+			},
+			"reassigned": schema.MapNestedAttribute{
+				Computed:    true,
+				Description: "The keys in both the prior and the new result that were assigned a different value, as of the most recent change to result. A warning listing these is also shown whenever a plan reassigns keys. This is unknown whenever result is or any value in result is not yet known.",
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"from": schema.StringAttribute{
+							Computed:    true,
+							Description: "The value the key was assigned in the prior result.",
+						},
+						"to": schema.StringAttribute{
+							Computed:    true,
+							Description: "The value the key is assigned in the new result.",
+						},
+					},
 				},
 			},
+			"removed_keys": schema.SetAttribute{
+				Computed:    true,
+				Description: "The keys in the prior result that are no longer in result, as of the most recent change to result. This is unknown whenever result is.",
+				ElementType: types.StringType,
+			},
+			"unassigned_keys": schema.SetAttribute{
+				Computed:    true,
+				Description: "The keys that were left without a value because there are not enough values. This is unknown whenever result is or any key is not yet known.",
+				ElementType: types.StringType,
+			},
+			"result_key_objects": schema.MapAttribute{
+				Computed:    true,
+				Description: "The stable mapping of the keys in result to the key_objects they were made from, only set when key_objects is used. This is unknown whenever result is.",
+				ElementType: types.MapType{ElemType: types.StringType},
+			},
+			"result_objects": schema.MapAttribute{
+				Computed:    true,
+				Description: "The stable mapping of keys to the objects of the value IDs they are assigned in result, only set when value_objects is used. This is unknown whenever result is.",
+				ElementType: types.MapType{ElemType: types.StringType},
+			},
 			"result": schema.MapAttribute{
 				Computed:    true,
-				Description: "The stable mapping of keys to values, size will be the smaller of the size of keys and values. The value will generally be known at plan time unless an unknown key can be assigned a value in which the whole result will be unknown but the end result will still be stable.",
+				Description: "The stable mapping of keys to values, size will be the smaller of the size of keys and the size of values times max_keys_per_value unless overflow makes room for every key. The value will generally be known at plan time unless an unknown key can be assigned a value in which the whole result will be unknown but the end result will still be stable.",
 				ElementType: types.StringType,
 			},
 		},
 	}
 }
 
-// Update ensures the plan value is copied to the state to complete the update.
+func (r *PairResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var model pairModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &model)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if model.Overflow.ValueString() == overflowGenerate {
+		switch {
+		case !model.ValueObjects.IsNull():
+			resp.Diagnostics.AddAttributeError(
+				path.Root("overflow"),
+				"Invalid Overflow",
+				"Values cannot be generated for value_objects, as there would be no objects for them.",
+			)
+		case model.OverflowTemplate.IsNull():
+			resp.Diagnostics.AddAttributeError(
+				path.Root("overflow_template"),
+				"Missing Overflow Template",
+				"overflow_template must be set when overflow is \"generate\".",
+			)
+		case !model.OverflowTemplate.IsUnknown() && !validTemplate(model.OverflowTemplate.ValueString()):
+			resp.Diagnostics.AddAttributeError(
+				path.Root("overflow_template"),
+				"Invalid Overflow Template",
+				fmt.Sprintf("%q must include the number of each generated value, for example with %%d.", model.OverflowTemplate.ValueString()),
+			)
+		}
+	}
+
+	var keys, values []normalizedElement
+
+	for _, key := range model.Keys.Elements() {
+		keys = append(keys, normalizedElement{path.Root("keys").AtSetValue(key), key})
+	}
+
+	if !model.KeyGenerator.IsNull() && fullyKnown(model.KeyGenerator) {
+		generated, diags := generate(ctx, model.KeyGenerator, path.Root("key_generator"))
+		resp.Diagnostics.Append(diags...)
+
+		for _, key := range generated {
+			keys = append(keys, normalizedElement{path.Root("key_generator"), types.StringValue(key)})
+		}
+	}
+
+	if !model.KeyAttributes.IsUnknown() && !model.KeySeparator.IsUnknown() {
+		for _, element := range model.KeyObjects.Elements() {
+			object, ok := element.(types.Map)
+			if !ok || object.IsNull() || object.IsUnknown() {
+				continue
+			}
+
+			for _, attribute := range model.KeyAttributes.Elements() {
+				attribute, ok := attribute.(types.String)
+				if !ok || attribute.IsUnknown() {
+					continue
+				}
+
+				if value, ok := object.Elements()[attribute.ValueString()]; !ok || value.IsNull() {
+					resp.Diagnostics.AddAttributeError(
+						path.Root("key_objects").AtSetValue(object),
+						"Missing Key Attribute",
+						fmt.Sprintf("Every element of key_objects must have the %q attribute listed in key_attributes.", attribute.ValueString()),
+					)
+				}
+			}
+		}
+
+		objects, _ := model.keyObjects()
+
+		seen := make(map[string]bool, len(objects))
+		for _, object := range objects {
+			if seen[object.key] {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("key_objects").AtSetValue(object.object),
+					"Duplicate Key",
+					fmt.Sprintf("More than one element of key_objects makes up the key %q, add an identity attribute to key_attributes or change key_separator.", object.key),
+				)
+			}
+
+			seen[object.key] = true
+			keys = append(keys, normalizedElement{path.Root("key_objects").AtSetValue(object.object), types.StringValue(object.key)})
+		}
+	}
+
+	tiers := make(map[string]int)
+
+	for i, tier := range model.ValueTiers.Elements() {
+		tier, ok := tier.(types.Set)
+		if !ok {
+			continue
+		}
+
+		for _, value := range tier.Elements() {
+			values = append(values, normalizedElement{path.Root("value_tiers").AtListIndex(i).AtSetValue(value), value})
+
+			value, ok := value.(basetypes.StringValuable)
+			if !ok || value.IsUnknown() || value.IsNull() {
+				continue
+			}
+
+			stringValue, diags := value.ToStringValue(ctx)
+			if diags.HasError() {
+				continue
+			}
+
+			if other, ok := tiers[stringValue.ValueString()]; ok {
+				resp.Diagnostics.AddAttributeError(
+					path.Root("value_tiers").AtListIndex(i).AtSetValue(value),
+					"Duplicate Value",
+					fmt.Sprintf("%q is in both tier %d and tier %d of value_tiers, a value can only be in one tier.", stringValue.ValueString(), other, i),
+				)
+				continue
+			}
+
+			tiers[stringValue.ValueString()] = i
+		}
+	}
+
+	for _, value := range model.Values.Elements() {
+		values = append(values, normalizedElement{path.Root("values").AtSetValue(value), value})
+	}
+
+	if !model.ValueGenerator.IsNull() && fullyKnown(model.ValueGenerator) {
+		generated, diags := generate(ctx, model.ValueGenerator, path.Root("value_generator"))
+		resp.Diagnostics.Append(diags...)
+
+		for _, value := range generated {
+			values = append(values, normalizedElement{path.Root("value_generator"), types.StringValue(value)})
+		}
+	}
+
+	for id := range model.ValueObjects.Elements() {
+		values = append(values, normalizedElement{path.Root("value_objects").AtMapKey(id), types.StringValue(id)})
+	}
+
+	validatePattern("Key", keys, model.KeyPattern, path.Root("key_pattern"), &resp.Diagnostics)
+	validatePattern("Value", values, model.ValuePattern, path.Root("value_pattern"), &resp.Diagnostics)
+
+	if model.Normalization.IsUnknown() {
+		return
+	}
+
+	validateNormalizedUnique("Key", keys, model.Normalization.ValueString(), &resp.Diagnostics)
+	validateNormalizedUnique("Value", values, model.Normalization.ValueString(), &resp.Diagnostics)
+}
+
+// Update applies the planned result to the state to complete the update.
 func (r *PairResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
 	var model pairModel
 
@@ -133,140 +773,2660 @@ func (r *PairResource) Update(ctx context.Context, req resource.UpdateRequest, r
 		return
 	}
 
-	// Read existing result field from state.
-	existingResult := make(map[string]types.String)
-	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("result"), &existingResult)...)
+	// Read existing result from state.
+	var state pairModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
 
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	convertedExistingResult := make(map[string]string, len(existingResult))
-	for key, value := range existingResult {
-		convertedExistingResult[key] = value.ValueString()
-	}
+	r.apply(ctx, model, state.prior(model), timestamp(), &resp.Diagnostics, &resp.State)
 
-	r.modify(ctx, model, convertedExistingResult, &resp.Diagnostics, &resp.State)
+	identify(ctx, resp.State, resp.Identity, &resp.Diagnostics)
 }
 
-func (r *PairResource) modify(ctx context.Context, model pairModel, existingResult map[string]string, diagnostics *diag.Diagnostics, state PlanOrState) {
-	keys := make([]basetypes.StringValue, len(model.Keys.Elements()))
-	diagnostics.Append(model.Keys.ElementsAs(ctx, &keys, false)...)
-	if diagnostics.HasError() {
+func (r *PairResource) modify(ctx context.Context, model pairModel, prior priorPairing, now types.String, diagnostics *diag.Diagnostics, state PlanOrState) {
+	// Nothing can be paired until both sets are at least partially known.
+	if !model.pairable() {
+		model.AssignmentMetadata = types.MapUnknown(types.ObjectType{AttrTypes: assignmentMetadataAttrTypes})
+		model.Assignments = types.SetUnknown(types.ObjectType{AttrTypes: assignmentAttrTypes})
+		model.Generation = types.Int64Unknown()
+		model.setSummary(unknownChangeSummary())
+		model.Grouped = types.MapUnknown(groupedType)
+		model.History = types.ListUnknown(types.ObjectType{AttrTypes: historyAttrTypes})
+		model.Inverse = types.MapUnknown(types.StringType)
+		model.Ordered = types.ListUnknown(types.ObjectType{AttrTypes: orderedAttrTypes})
+		model.Result = types.MapUnknown(types.StringType)
+		model.ResultKeyObjects = types.MapNull(resultObjectType)
+		model.ResultObjects = types.MapNull(resultObjectType)
+		model.UnassignedKeys = types.SetUnknown(types.StringType)
+		model.setID(prior)
+
+		if !model.KeyObjects.IsNull() {
+			model.ResultKeyObjects = types.MapUnknown(resultObjectType)
+		}
+
+		if !model.ValueObjects.IsNull() {
+			model.ResultObjects = types.MapUnknown(resultObjectType)
+		}
+
+		diagnostics.Append(state.Set(ctx, model)...)
 		return
 	}
 
-	values := make([]basetypes.StringValue, len(model.Values.Elements()))
-	diagnostics.Append(model.Values.ElementsAs(ctx, &values, false)...)
+	keys, values := r.elements(ctx, model, diagnostics)
 	if diagnostics.HasError() {
 		return
 	}
 
-	result := pairStable(existingResult, keys, values)
+	p, reassigning := r.pair(model, prior, keys, values, diagnostics)
+	if diagnostics.HasError() {
+		return
+	}
 
-	var diags diag.Diagnostics
-	model.Result, diags = types.MapValueFrom(ctx, types.StringType, result)
-	diagnostics.Append(diags...)
+	r.enforce(model, prior, keys, p, reassigning, diagnostics)
 	if diagnostics.HasError() {
 		return
 	}
 
-	diagnostics.Append(state.Set(ctx, model)...)
+	if prior.adopting {
+		r.adopt(model, prior, keys, values, p, diagnostics)
+	}
+
+	r.overflow(model, p, diagnostics)
 	if diagnostics.HasError() {
 		return
 	}
-}
 
-type pairModel struct {
-	ID     types.String `tfsdk:"id"`
-	Keys   types.Set    `tfsdk:"keys"`
-	Result types.Map    `tfsdk:"result"`
-	Values types.Set    `tfsdk:"values"`
+	if reassigned := p.reassigned(prior.result); len(reassigned) > 0 {
+		diagnostics.AddAttributeWarning(
+			path.Root("reassigned"),
+			"Assignments Will Change",
+			fmt.Sprintf("The following keys will be assigned a different value:\n\n%s", strings.Join(reassigned, "\n")),
+		)
+	}
+
+	r.set(ctx, model, prior, now, p, diagnostics, state)
 }
 
-func pairStable(existingResult map[string]string, keys, values []basetypes.StringValue) basetypes.MapValue {
-	// First up, make a map each of keys and values to allow for easy logic below.
-	keyMapping := make(map[string]bool)
-	keysUnknown := 0
-	valueMapping := make(map[string]bool)
-	valuesUnknown := 0
+// enforce adds an error for every configured key that would lose or change the value it has in the prior
+// result against change_policy or locked_keys. Keys being reassigned through reassign_keys are exempt from
+// change_policy but not from locked_keys.
+func (r *PairResource) enforce(model pairModel, prior priorPairing, keys []basetypes.StringValue, p pairing, reassigning map[string]bool, diagnostics *diag.Diagnostics) {
+	approved := stringSet(model.ApprovedMoves)
+	locked := stringSet(model.LockedKeys)
 
-	for _, key := range keys {
-		if key.IsUnknown() {
-			keysUnknown += 1
-		} else {
-			keyMapping[key.ValueString()] = true
-		}
+	policy := model.ChangePolicy.ValueString()
+	if len(locked) == 0 && (policy == "" || policy == changePolicyAllow) {
+		return
 	}
 
-	for _, value := range values {
-		if value.IsUnknown() {
-			valuesUnknown += 1
-		} else {
-			valueMapping[value.ValueString()] = true
+	// Keys and values are compared as normalized, so that respelling them changes nothing.
+	existingKeys := p.normalizedKeys(prior.result)
+
+	normalizedSet := func(set map[string]bool) map[string]bool {
+		normalized := make(map[string]bool, len(set))
+		for element := range set {
+			normalized[normalize(p.normalization, element)] = true
 		}
+
+		return normalized
 	}
 
-	// Given an existing mapping, determine which of those should persist. If a key
-	// is no longer present, no value needs to be assigned. However, if a value is
-	// no longer present, a new one needs to be assigned. The latter is easily
-	// achieved by leaving it out of the trimmed mapping and then allowing the
-	// logic below for new keys take care of that.
-	finalMapping := make(map[string]attr.Value)
-	valuesUsed := make(map[string]bool)
+	approved, locked = normalizedSet(approved), normalizedSet(locked)
 
-	for key, value := range existingResult {
-		if _, ok := keyMapping[key]; !ok {
+	for _, key := range keys {
+		if key.IsUnknown() {
 			continue
 		}
 
-		if _, ok := valueMapping[value]; !ok {
+		existingKey, ok := existingKeys[normalize(p.normalization, key.ValueString())]
+		if !ok {
 			continue
 		}
 
-		finalMapping[key] = basetypes.NewStringValue(value)
-		valuesUsed[value] = true
+		existing := prior.result[existingKey]
+
+		value, ok := p.mapping[key.ValueString()].(basetypes.StringValue)
+		if ok && !value.IsUnknown() && p.same(value.ValueString(), existing) {
+			continue
+		}
+
+		to := "no value"
+		if ok {
+			to = "a value known after apply"
+			if !value.IsUnknown() {
+				to = fmt.Sprintf("%q", value.ValueString())
+			}
+		}
+
+		switch {
+		case locked[normalize(p.normalization, key.ValueString())]:
+			diagnostics.AddAttributeError(
+				path.Root("result").AtMapKey(key.ValueString()),
+				"Locked Key Reassigned",
+				fmt.Sprintf("The key %q is in locked_keys, so it cannot go from %q to %s.", key.ValueString(), existing, to),
+			)
+		case reassigning[key.ValueString()]:
+		case policy == changePolicyStrict:
+			diagnostics.AddAttributeError(
+				path.Root("result").AtMapKey(key.ValueString()),
+				"Assignment Change Not Allowed",
+				fmt.Sprintf("The change_policy is %q, so the key %q cannot go from %q to %s.", policy, key.ValueString(), existing, to),
+			)
+		case policy == changePolicyApprovedOnly && !approved[normalize(p.normalization, key.ValueString())]:
+			diagnostics.AddAttributeError(
+				path.Root("result").AtMapKey(key.ValueString()),
+				"Assignment Change Not Approved",
+				fmt.Sprintf("The change_policy is %q and the key %q is not in approved_moves, so it cannot go from %q to %s. Add it to approved_moves to allow this.", policy, key.ValueString(), existing, to),
+			)
+		}
+	}
+}
+
+// adopt adds a warning for every entry of the result being adopted that the pairing does not keep.
+// Nothing is said about keys that could still turn out to be configured or values that could still be assigned
+// once everything is known.
+func (r *PairResource) adopt(model pairModel, prior priorPairing, keys, values []basetypes.StringValue, p pairing, diagnostics *diag.Diagnostics) {
+	mode := model.Normalization.ValueString()
+
+	configured := func(elements []basetypes.StringValue) map[string]bool {
+		known := make(map[string]bool, len(elements))
+		for _, element := range elements {
+			if !element.IsUnknown() {
+				known[normalize(mode, element.ValueString())] = true
+			}
+		}
+
+		return known
+	}
+
+	configuredKeys, configuredValues := configured(keys), configured(values)
+
+	adoptedFrom := path.Root("initial_result")
+	if prior.exists {
+		adoptedFrom = path.Root("result")
+	}
+
+	adopted := make(map[string]string, len(p.mapping))
+	for key, value := range p.mapping {
+		if value, ok := value.(basetypes.StringValue); ok && !value.IsUnknown() {
+			adopted[normalize(mode, key)] = normalize(mode, value.ValueString())
+		}
+	}
+
+	initialKeys := make([]string, 0, len(prior.result))
+	for key := range prior.result {
+		initialKeys = append(initialKeys, key)
+	}
+
+	sort.Strings(initialKeys)
+
+	for _, key := range initialKeys {
+		value := prior.result[key]
+
+		var detail string
+		switch {
+		case adopted[normalize(mode, key)] == normalize(mode, value):
+			continue
+		case !configuredKeys[normalize(mode, key)]:
+			if p.keysUnknown > 0 {
+				continue
+			}
+
+			detail = fmt.Sprintf("The key %q is not configured, so its assignment to %q is left out.", key, value)
+		case !configuredValues[normalize(mode, value)]:
+			if p.valuesUnknown > 0 {
+				continue
+			}
+
+			detail = fmt.Sprintf("The value %q is not configured, so the key %q is assigned a value as usual instead.", value, key)
+		default:
+			if value, ok := p.mapping[key].(basetypes.StringValue); ok && value.IsUnknown() {
+				continue
+			}
+
+			detail = fmt.Sprintf("The key %q cannot keep the value %q, as the value has no room left for it, so it is assigned a value as usual instead.", key, value)
+		}
+
+		diagnostics.AddAttributeWarning(adoptedFrom.AtMapKey(key), "Assignment Not Adopted", detail)
+	}
+}
+
+// overflow adds an error or a warning, depending on overflow, for every key left without a value.
+func (r *PairResource) overflow(model pairModel, p pairing, diagnostics *diag.Diagnostics) {
+	overflow := model.Overflow.ValueString()
+	if overflow != overflowError && overflow != overflowWarn {
+		return
+	}
+
+	for _, key := range p.unassigned {
+		summary := "Key Left Unassigned"
+		detail := fmt.Sprintf("There are not enough values for the key %q to be assigned one. Add values, raise max_keys_per_value or change overflow.", key)
+
+		if overflow == overflowError {
+			diagnostics.AddAttributeError(model.keyPath(key), summary, detail)
+		} else {
+			diagnostics.AddAttributeWarning(model.keyPath(key), summary, detail)
+		}
+	}
+}
+
+// validTemplate returns true when template formats a single number, so that every number makes up a different
+// value.
+func validTemplate(template string) bool {
+	first, second := fmt.Sprintf(template, 1), fmt.Sprintf(template, 2)
+
+	return first != second && !strings.Contains(first, "%!")
+}
+
+// keyPath returns the path of the element of keys or key_objects that makes up key.
+func (m pairModel) keyPath(key string) path.Path {
+	if !m.KeyGenerator.IsNull() {
+		return path.Root("key_generator")
+	}
+
+	if m.KeyObjects.IsNull() {
+		return path.Root("keys").AtSetValue(types.StringValue(key))
+	}
+
+	objects, _ := m.keyObjects()
+	for _, object := range objects {
+		if object.key == key {
+			return path.Root("key_objects").AtSetValue(object.object)
+		}
+	}
+
+	return path.Root("key_objects")
+}
+
+// valuesPath returns the path of whichever of values, value_tiers or value_objects the values come from.
+func (m pairModel) valuesPath() path.Path {
+	switch {
+	case !m.ValueTiers.IsNull():
+		return path.Root("value_tiers")
+	case !m.ValueObjects.IsNull():
+		return path.Root("value_objects")
+	}
+
+	return path.Root("values")
+}
+
+// knownElements returns true when m and each of its elements are known.
+func knownElements(m types.Map) bool {
+	if m.IsUnknown() {
+		return false
+	}
+
+	for _, element := range m.Elements() {
+		if element.IsUnknown() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stringSet returns the known elements of a set of strings.
+func stringSet(set types.Set) map[string]bool {
+	elements := make(map[string]bool, len(set.Elements()))
+	for _, element := range set.Elements() {
+		if element, ok := element.(types.String); ok && !element.IsUnknown() && !element.IsNull() {
+			elements[element.ValueString()] = true
+		}
+	}
+
+	return elements
+}
+
+// pair pairs keys and values starting from the prior result, or the rolled back one, forcing every key whose
+// reassign_keys nonce changed onto a different value and then migrating between tiers and rebalancing if asked
+// to. It returns the pairing along with the keys that were forced to move.
+func (r *PairResource) pair(model pairModel, prior priorPairing, keys, values []basetypes.StringValue, diagnostics *diag.Diagnostics) (pairing, map[string]bool) {
+	seed := r.seed(model, prior, diagnostics)
+	if diagnostics.HasError() {
+		return pairing{}, nil
+	}
+
+	// Keys being reassigned are left out of the seed, so that nothing else moves, and kept off the values they
+	// give up.
+	reassigning := make(map[string]bool)
+	givenUp := make(map[string]string)
+
+	configured := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		configured[key.ValueString()] = !key.IsUnknown()
+	}
+
+	if prior.exists {
+		for key, nonce := range model.ReassignKeys.Elements() {
+			nonce, ok := nonce.(types.String)
+			if !ok || nonce.IsNull() {
+				continue
+			}
+
+			if priorNonce, ok := prior.reassignKeys[key]; ok && priorNonce == nonce.ValueString() {
+				continue
+			}
+
+			if value, ok := seed[key]; ok && configured[key] {
+				reassigning[key] = true
+				givenUp[key] = value
+			}
+		}
+	}
+
+	if len(reassigning) > 0 {
+		kept := make(map[string]string, len(seed))
+		for key, value := range seed {
+			if !reassigning[key] {
+				kept[key] = value
+			}
+		}
+
+		seed = kept
+	}
+
+	options := model.options(keys, values)
+	options.excluded = givenUp
+
+	// Keys that lose their value are assigned one like it, if the prior state knows what it was like. Keys
+	// being reassigned are not, as that would likely be the value they were on.
+	if !model.LocalityAttributes.IsNull() {
+		options.locality = make(map[string][]basetypes.StringValue)
+		for key, value := range seed {
+			if object, ok := prior.valueObjects.Elements()[value]; ok && !reassigning[key] {
+				options.locality[key] = model.locality(object)
+			}
+		}
+	}
+
+	p := pair(seed, keys, values, options)
+
+	for key := range reassigning {
+		if value, ok := p.mapping[key].(basetypes.StringValue); ok && !value.IsUnknown() && value.ValueString() != prior.result[key] {
+			continue
+		}
+
+		diagnostics.AddAttributeError(
+			path.Root("reassign_keys").AtMapKey(key),
+			"Unable to Reassign Key",
+			fmt.Sprintf("The key %q was asked to be reassigned, but there is no free value to move it to.", key),
+		)
+	}
+
+	// Rebalancing is left for a later plan while anything is unknown, as the moves could not be told apart
+	// from how the unknown keys and values turn out.
+	if p.keysUnknown > 0 || p.valuesUnknown > 0 {
+		return p, reassigning
+	}
+
+	approved := stringSet(model.ApprovedMoves)
+	locked := stringSet(model.LockedKeys)
+
+	// Migrating and rebalancing only move keys that change_policy and locked_keys allow to move.
+	movable := func(key string) bool {
+		if locked[key] || reassigning[key] {
+			return false
+		}
+
+		switch model.ChangePolicy.ValueString() {
+		case changePolicyStrict:
+			return false
+		case changePolicyApprovedOnly:
+			return approved[key]
+		}
+
+		return true
+	}
+
+	if model.MigrateToHigherTiers.ValueBool() {
+		maxMoves := -1
+		if !model.TierMigrationMaxMoves.IsNull() {
+			maxMoves = int(model.TierMigrationMaxMoves.ValueInt64())
+		}
+
+		p = migrate(p, values, options, maxMoves, movable)
+	}
+
+	tolerance := -1
+	if !model.RebalanceTolerance.IsNull() {
+		tolerance = int(model.RebalanceTolerance.ValueInt64())
+	}
+
+	if prior.exists && !model.RebalanceTrigger.IsNull() && !model.RebalanceTrigger.Equal(prior.rebalanceTrigger) {
+		tolerance = 1
+	}
+
+	if tolerance < 0 {
+		return p, reassigning
+	}
+
+	maxMoves := -1
+	if !model.RebalanceMaxMoves.IsNull() {
+		maxMoves = int(model.RebalanceMaxMoves.ValueInt64())
+	}
+
+	return rebalance(p, values, options, tolerance, maxMoves, movable), reassigning
+}
+
+// seed returns the existing result to pair from, which is the prior result unless keepers changed, in which case
+// it is empty, or rollback_to_generation was just set or changed, in which case it is the result of that
+// generation from the history.
+func (r *PairResource) seed(model pairModel, prior priorPairing, diagnostics *diag.Diagnostics) map[string]string {
+	if prior.exists && !model.Keepers.Equal(prior.keepers) {
+		return map[string]string{}
+	}
+
+	if !prior.exists || model.RollbackToGeneration.IsNull() || model.RollbackToGeneration.Equal(prior.rollbackToGeneration) {
+		return prior.result
+	}
+
+	generations := make([]string, 0, len(prior.history))
+	for _, entry := range prior.history {
+		if entry.generation == model.RollbackToGeneration.ValueInt64() {
+			return entry.result
+		}
+
+		generations = append(generations, strconv.FormatInt(entry.generation, 10))
+	}
+
+	diagnostics.AddAttributeError(
+		path.Root("rollback_to_generation"),
+		"Unknown Generation",
+		fmt.Sprintf("Generation %d is not in the history, which holds the following generations: %s.", model.RollbackToGeneration.ValueInt64(), strings.Join(generations, ", ")),
+	)
+
+	return nil
+}
+
+// apply completes a planned change. The planned result is taken as authoritative so that exactly what was
+// shown in the plan gets applied, with only the assignments that were unknown at plan time being resolved now
+// that every key and value is known.
+func (r *PairResource) apply(ctx context.Context, model pairModel, prior priorPairing, now types.String, diagnostics *diag.Diagnostics, state PlanOrState) {
+	if model.Result.IsNull() || model.Result.IsUnknown() {
+		r.modify(ctx, model, prior, now, diagnostics, state)
+		return
+	}
+
+	keys, values := r.elements(ctx, model, diagnostics)
+	if diagnostics.HasError() {
+		return
+	}
+
+	planned := make(map[string]types.String, len(model.Result.Elements()))
+	diagnostics.Append(model.Result.ElementsAs(ctx, &planned, false)...)
+	if diagnostics.HasError() {
+		return
+	}
+
+	plannedResult := make(map[string]string, len(planned))
+	for key, value := range planned {
+		if !value.IsUnknown() {
+			plannedResult[key] = value.ValueString()
+		}
+	}
+
+	// Pairing from the known planned assignments keeps every one of them, so anything that comes out
+	// differently means the plan no longer fits the keys and values.
+	p := pair(plannedResult, keys, values, model.options(keys, values))
+
+	var mismatched []string
+	for key, value := range planned {
+		if resolved, ok := p.mapping[key]; !ok || resolved.IsUnknown() || (!value.IsUnknown() && !resolved.Equal(value)) {
+			mismatched = append(mismatched, key)
+		}
+	}
+
+	for key := range p.mapping {
+		if _, ok := planned[key]; !ok {
+			mismatched = append(mismatched, key)
+		}
+	}
+
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+
+		diagnostics.AddAttributeError(
+			path.Root("result"),
+			"Unable to Apply Planned Result",
+			fmt.Sprintf("The planned result does not fit the configured keys and values for the following keys: %s. This happens when a key or value that was unknown at plan time turns out to be the same as a known one, which leaves no room for the planned assignments. Otherwise, please report the following to the provider developer:\n\nplanned %s", strings.Join(mismatched, ", "), model.Result),
+		)
+		return
+	}
+
+	// Keys left without a value were only all known at plan time if unassigned_keys was.
+	if model.UnassignedKeys.IsUnknown() {
+		r.overflow(model, p, diagnostics)
+		if diagnostics.HasError() {
+			return
+		}
+	}
+
+	r.set(ctx, model, prior, now, p, diagnostics, state)
+}
+
+// elements returns the elements of the keys and values sets, or of whichever attributes are used instead, along
+// with any values generated for keys to overflow onto.
+func (r *PairResource) elements(ctx context.Context, model pairModel, diagnostics *diag.Diagnostics) ([]basetypes.StringValue, []basetypes.StringValue) {
+	keys, values := r.configuredElements(ctx, model, diagnostics)
+	if diagnostics.HasError() || model.Overflow.ValueString() != overflowGenerate {
+		return keys, values
+	}
+
+	// Enough values are generated for every key to have room, numbered from one skipping any that would make up
+	// a configured value, so that the same values are generated for as long as they are needed.
+	configured := make(map[string]bool, len(values))
+	for _, value := range values {
+		configured[value.ValueString()] = !value.IsUnknown()
+	}
+
+	capacity := model.options(keys, values).capacity()
+	needed := (len(keys)+capacity-1)/capacity - len(values)
+
+	for i := 1; needed > 0; i++ {
+		value := fmt.Sprintf(model.OverflowTemplate.ValueString(), i)
+		if configured[value] {
+			continue
+		}
+
+		values = append(values, basetypes.NewStringValue(value))
+		needed -= 1
+	}
+
+	return keys, values
+}
+
+// configuredElements returns the elements of the keys and values sets, or of whichever attributes are used
+// instead.
+func (r *PairResource) configuredElements(ctx context.Context, model pairModel, diagnostics *diag.Diagnostics) ([]basetypes.StringValue, []basetypes.StringValue) {
+	keys := make([]basetypes.StringValue, len(model.Keys.Elements()))
+	diagnostics.Append(model.Keys.ElementsAs(ctx, &keys, false)...)
+	if diagnostics.HasError() {
+		return nil, nil
+	}
+
+	// Key objects are paired by the keys they make up, any with unknown identity attributes being unknown keys.
+	if !model.KeyObjects.IsNull() {
+		objects, unknown := model.keyObjects()
+
+		keys = make([]basetypes.StringValue, 0, len(objects)+unknown)
+		for _, object := range objects {
+			keys = append(keys, basetypes.NewStringValue(object.key))
+		}
+
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].ValueString() < keys[j].ValueString()
+		})
+
+		for range unknown {
+			keys = append(keys, basetypes.NewStringUnknown())
+		}
+	}
+
+	if !model.KeyGenerator.IsNull() {
+		generated, diags := generate(ctx, model.KeyGenerator, path.Root("key_generator"))
+		diagnostics.Append(diags...)
+		if diagnostics.HasError() {
+			return nil, nil
+		}
+
+		keys = make([]basetypes.StringValue, len(generated))
+		for i, key := range generated {
+			keys[i] = basetypes.NewStringValue(key)
+		}
+	}
+
+	if !model.ValueGenerator.IsNull() {
+		generated, diags := generate(ctx, model.ValueGenerator, path.Root("value_generator"))
+		diagnostics.Append(diags...)
+		if diagnostics.HasError() {
+			return nil, nil
+		}
+
+		values := make([]basetypes.StringValue, len(generated))
+		for i, value := range generated {
+			values[i] = basetypes.NewStringValue(value)
+		}
+
+		return keys, values
+	}
+
+	// Value objects are paired by their IDs, sorted to match the order Terraform gives set elements in.
+	if !model.ValueObjects.IsNull() {
+		ids := make([]string, 0, len(model.ValueObjects.Elements()))
+		for id := range model.ValueObjects.Elements() {
+			ids = append(ids, id)
+		}
+
+		sort.Strings(ids)
+
+		values := make([]basetypes.StringValue, len(ids))
+		for i, id := range ids {
+			values[i] = basetypes.NewStringValue(id)
+		}
+
+		return keys, values
+	}
+
+	// Value tiers are paired as one set of values highest tier first, which is the order options gives them
+	// tiers in.
+	if !model.ValueTiers.IsNull() {
+		var values []basetypes.StringValue
+
+		for _, tier := range model.ValueTiers.Elements() {
+			tier, ok := tier.(types.Set)
+			if !ok {
+				continue
+			}
+
+			elements := make([]basetypes.StringValue, len(tier.Elements()))
+			diagnostics.Append(tier.ElementsAs(ctx, &elements, false)...)
+			if diagnostics.HasError() {
+				return nil, nil
+			}
+
+			values = append(values, elements...)
+		}
+
+		return keys, values
+	}
+
+	values := make([]basetypes.StringValue, len(model.Values.Elements()))
+	diagnostics.Append(model.Values.ElementsAs(ctx, &values, false)...)
+	if diagnostics.HasError() {
+		return nil, nil
+	}
+
+	return keys, values
+}
+
+// set stores the pairing into state along with everything derived from it. The now timestamp is when the
+// change is applied and is unknown while planning.
+func (r *PairResource) set(ctx context.Context, model pairModel, prior priorPairing, now types.String, p pairing, diagnostics *diag.Diagnostics, state PlanOrState) {
+	var diags diag.Diagnostics
+	model.Result, diags = types.MapValueFrom(ctx, types.StringType, p.result())
+	diagnostics.Append(diags...)
+	if diagnostics.HasError() {
+		return
+	}
+
+	model.setID(prior)
+
+	model.Grouped = types.MapUnknown(groupedType)
+	model.Inverse = types.MapUnknown(types.StringType)
+	model.Ordered = types.ListUnknown(types.ObjectType{AttrTypes: orderedAttrTypes})
+	model.setSummary(unknownChangeSummary())
+	model.AssignmentMetadata = types.MapUnknown(types.ObjectType{AttrTypes: assignmentMetadataAttrTypes})
+	model.Generation = types.Int64Unknown()
+	model.History = types.ListUnknown(types.ObjectType{AttrTypes: historyAttrTypes})
+	model.UnassignedKeys = types.SetUnknown(types.StringType)
+
+	if !model.Result.IsUnknown() && p.keysUnknown == 0 {
+		model.UnassignedKeys = p.unassignedKeys()
+	}
+
+	if !model.Result.IsUnknown() {
+		model.Ordered = p.ordered(prior.indexes)
+
+		// The summary and metadata describe the most recent change to the result, so they are kept as long as
+		// nothing changes.
+		if prior.exists && p.unchanged(prior.result) {
+			model.setSummary(prior.summary)
+			model.AssignmentMetadata = prior.metadata
+			model.Generation = prior.generation
+			model.History = prior.historyValue(nil, model.HistorySize.ValueInt64())
+		} else {
+			model.setSummary(p.summary(prior.result))
+			model.AssignmentMetadata, model.Generation = p.metadata(prior, now)
+			model.History = types.ListUnknown(types.ObjectType{AttrTypes: historyAttrTypes})
+
+			if !model.Generation.IsUnknown() {
+				model.History = prior.historyValue(&historyEntry{
+					generation: model.Generation.ValueInt64(),
+					result:     p.known(),
+				}, model.HistorySize.ValueInt64())
+			}
+		}
+
+		if model.HistorySize.IsUnknown() {
+			model.History = types.ListUnknown(types.ObjectType{AttrTypes: historyAttrTypes})
+		}
+
+		// Values become keys of the inverse views, so those cannot be known until every value is.
+		if !p.valuesPending() {
+			model.Inverse, model.Grouped = p.inverse()
+		}
+	}
+
+	model.ResultObjects = types.MapNull(resultObjectType)
+	if !model.ValueObjects.IsNull() {
+		model.ResultObjects = types.MapUnknown(resultObjectType)
+
+		if !model.Result.IsUnknown() {
+			objects := make(map[string]attr.Value, len(model.Result.Elements()))
+
+			for key, id := range model.Result.Elements() {
+				objects[key] = types.MapUnknown(types.StringType)
+
+				if id, ok := id.(types.String); ok && !id.IsUnknown() {
+					objects[key] = model.ValueObjects.Elements()[id.ValueString()]
+				}
+			}
+
+			model.ResultObjects, diags = types.MapValue(resultObjectType, objects)
+			diagnostics.Append(diags...)
+			if diagnostics.HasError() {
+				return
+			}
+		}
+	}
+
+	model.ResultKeyObjects = types.MapNull(resultObjectType)
+	if !model.KeyObjects.IsNull() {
+		model.ResultKeyObjects = types.MapUnknown(resultObjectType)
+
+		if !model.Result.IsUnknown() {
+			objects, _ := model.keyObjects()
+
+			objectsByKey := make(map[string]types.Map, len(objects))
+			for _, object := range objects {
+				objectsByKey[object.key] = object.object
+			}
+
+			resultKeyObjects := make(map[string]attr.Value, len(model.Result.Elements()))
+			for key := range model.Result.Elements() {
+				resultKeyObjects[key] = objectsByKey[key]
+			}
+
+			model.ResultKeyObjects, diags = types.MapValue(resultObjectType, resultKeyObjects)
+			diagnostics.Append(diags...)
+			if diagnostics.HasError() {
+				return
+			}
+		}
+	}
+
+	var unknown int
+	model.Assignments, unknown = p.assignments()
+
+	if unknown > 0 {
+		if p.keysUnknown > 0 {
+			keysPath := path.Root("keys")
+			if !model.KeyObjects.IsNull() {
+				keysPath = path.Root("key_objects")
+			}
+
+			diagnostics.AddAttributeWarning(
+				keysPath,
+				"Assignments Depend On Unknown Keys",
+				fmt.Sprintf("%d of the keys will not be known until apply, so %d of the assignments cannot be determined at plan time. Assignments kept from the prior state remain known.", p.keysUnknown, unknown),
+			)
+		}
+
+		if p.valuesUnknown > 0 {
+			diagnostics.AddAttributeWarning(
+				model.valuesPath(),
+				"Assignments Depend On Unknown Values",
+				fmt.Sprintf("%d of the values will not be known until apply, so %d of the assignments cannot be determined at plan time. Assignments kept from the prior state remain known.", p.valuesUnknown, unknown),
+			)
+		}
+	}
+
+	diagnostics.Append(state.Set(ctx, model)...)
+	if diagnostics.HasError() {
+		return
+	}
+}
+
+type pairModel struct {
+	AddedKeys             types.Set     `tfsdk:"added_keys"`
+	ApprovedMoves         types.Set     `tfsdk:"approved_moves"`
+	AssignmentMetadata    types.Map     `tfsdk:"assignment_metadata"`
+	Assignments           types.Set     `tfsdk:"assignments"`
+	ChangePolicy          types.String  `tfsdk:"change_policy"`
+	ChurnRatio            types.Float64 `tfsdk:"churn_ratio"`
+	FreedValues           types.Set     `tfsdk:"freed_values"`
+	Generation            types.Int64   `tfsdk:"generation"`
+	Grouped               types.Map     `tfsdk:"grouped"`
+	History               types.List    `tfsdk:"history"`
+	HistorySize           types.Int64   `tfsdk:"history_size"`
+	ID                    types.String  `tfsdk:"id"`
+	InitialResult         types.Map     `tfsdk:"initial_result"`
+	Inverse               types.Map     `tfsdk:"inverse"`
+	Keepers               types.Map     `tfsdk:"keepers"`
+	KeyAttributes         types.List    `tfsdk:"key_attributes"`
+	KeyGenerator          types.Object  `tfsdk:"key_generator"`
+	KeyObjects            types.Set     `tfsdk:"key_objects"`
+	KeyPattern            types.String  `tfsdk:"key_pattern"`
+	KeySeparator          types.String  `tfsdk:"key_separator"`
+	Keys                  types.Set     `tfsdk:"keys"`
+	LocalityAttributes    types.List    `tfsdk:"locality_attributes"`
+	LockedKeys            types.Set     `tfsdk:"locked_keys"`
+	MaxKeysPerValue       types.Int64   `tfsdk:"max_keys_per_value"`
+	MigrateToHigherTiers  types.Bool    `tfsdk:"migrate_to_higher_tiers"`
+	Name                  types.String  `tfsdk:"name"`
+	Normalization         types.String  `tfsdk:"normalization"`
+	Ordered               types.List    `tfsdk:"ordered"`
+	ReassignKeys          types.Map     `tfsdk:"reassign_keys"`
+	Overflow              types.String  `tfsdk:"overflow"`
+	OverflowTemplate      types.String  `tfsdk:"overflow_template"`
+	RebalanceMaxMoves     types.Int64   `tfsdk:"rebalance_max_moves"`
+	RebalanceTolerance    types.Int64   `tfsdk:"rebalance_tolerance"`
+	RebalanceTrigger      types.String  `tfsdk:"rebalance_trigger"`
+	Reassigned            types.Map     `tfsdk:"reassigned"`
+	RemovedKeys           types.Set     `tfsdk:"removed_keys"`
+	Result                types.Map     `tfsdk:"result"`
+	ResultKeyObjects      types.Map     `tfsdk:"result_key_objects"`
+	ResultObjects         types.Map     `tfsdk:"result_objects"`
+	RollbackToGeneration  types.Int64   `tfsdk:"rollback_to_generation"`
+	TierMigrationMaxMoves types.Int64   `tfsdk:"tier_migration_max_moves"`
+	UnassignedKeys        types.Set     `tfsdk:"unassigned_keys"`
+	ValueGenerator        types.Object  `tfsdk:"value_generator"`
+	ValueObjects          types.Map     `tfsdk:"value_objects"`
+	ValuePattern          types.String  `tfsdk:"value_pattern"`
+	ValueTiers            types.List    `tfsdk:"value_tiers"`
+	Values                types.Set     `tfsdk:"values"`
+}
+
+// Change policies which decide whether existing assignments may change.
+const (
+	changePolicyAllow        = "allow"
+	changePolicyStrict       = "strict"
+	changePolicyApprovedOnly = "approved_only"
+)
+
+var changePolicies = []string{
+	changePolicyAllow,
+	changePolicyStrict,
+	changePolicyApprovedOnly,
+}
+
+// Overflows which decide what happens to keys that there are not enough values for.
+const (
+	overflowLeaveUnassigned = "leave_unassigned"
+	overflowError           = "error"
+	overflowWarn            = "warn"
+	overflowShare           = "share"
+	overflowGenerate        = "generate"
+)
+
+var overflows = []string{
+	overflowLeaveUnassigned,
+	overflowError,
+	overflowWarn,
+	overflowShare,
+	overflowGenerate,
+}
+
+// defaultHistorySize is the number of results kept in history when history_size is not set.
+const defaultHistorySize = 10
+
+// defaultKeySeparator joins the identity attributes of key objects when key_separator is not set.
+const defaultKeySeparator = "/"
+
+// keyObject is an element of key_objects along with the key it makes up.
+type keyObject struct {
+	// key is the identity attribute values joined by the separator, used as the key in result.
+	key string
+
+	// identity is the identity attribute values encoded as a JSON array, which unlike key does not depend on
+	// the separator.
+	identity string
+
+	object types.Map
+}
+
+// keyObjects returns the key objects whose identity attributes are all known and how many are not.
+func (m pairModel) keyObjects() ([]keyObject, int) {
+	var attributes []string
+	for _, attribute := range m.KeyAttributes.Elements() {
+		if attribute, ok := attribute.(types.String); ok {
+			attributes = append(attributes, attribute.ValueString())
+		}
+	}
+
+	separator := defaultKeySeparator
+	if !m.KeySeparator.IsNull() {
+		separator = m.KeySeparator.ValueString()
+	}
+
+	var objects []keyObject
+	unknown := 0
+
+	for _, element := range m.KeyObjects.Elements() {
+		object, ok := element.(types.Map)
+		if !ok || object.IsNull() {
+			continue
+		}
+
+		if object.IsUnknown() {
+			unknown += 1
+			continue
+		}
+
+		parts := make([]string, 0, len(attributes))
+		for _, attribute := range attributes {
+			value, ok := object.Elements()[attribute].(types.String)
+			if !ok || value.IsNull() {
+				break
+			}
+
+			if value.IsUnknown() {
+				unknown += 1
+				break
+			}
+
+			parts = append(parts, value.ValueString())
+		}
+
+		// Objects missing an identity attribute are reported by ValidateConfig.
+		if len(parts) != len(attributes) {
+			continue
+		}
+
+		identity, err := json.Marshal(parts)
+		if err != nil {
+			continue
+		}
+
+		objects = append(objects, keyObject{
+			key:      strings.Join(parts, separator),
+			identity: string(identity),
+			object:   object,
+		})
+	}
+
+	return objects, unknown
+}
+
+// priorPairing is what a prior state holds that is kept stable when pairing.
+type priorPairing struct {
+	// exists is false when there is no prior state.
+	exists bool
+
+	// adopting is true when result is being adopted, either from initial_result when there is no prior state or
+	// from a state that was just imported, so that anything in it that is not kept is reported.
+	adopting bool
+
+	// result is the prior result.
+	result map[string]string
+
+	// id is the prior id and name is the prior name.
+	id   types.String
+	name types.String
+
+	// indexes is the prior index of each key in ordered.
+	indexes map[string]int64
+
+	// summary is the prior change summary.
+	summary changeSummary
+
+	// generation is the prior generation and metadata is the prior metadata of each key.
+	generation types.Int64
+	metadata   types.Map
+
+	// history holds the prior results by generation, oldest first, and is nil when the prior state has none.
+	history []historyEntry
+
+	// rollbackToGeneration is the prior rollback_to_generation, which has already been rolled back to.
+	rollbackToGeneration types.Int64
+
+	// reassignKeys holds the prior reassign_keys nonces, whose keys have already been reassigned.
+	reassignKeys map[string]string
+
+	// keepers is the prior keepers.
+	keepers types.Map
+
+	// rebalanceTrigger is the prior rebalance_trigger, which has already been rebalanced for.
+	rebalanceTrigger types.String
+
+	// valueObjects is the prior value_objects, which still has the values that have since been removed.
+	valueObjects types.Map
+}
+
+// historyEntry is an element of the history attribute.
+type historyEntry struct {
+	generation int64
+	result     map[string]string
+}
+
+// historyValue returns the prior history with entry appended, if any, keeping only the newest size entries.
+// A prior state without history is kept that way until there is an entry to add.
+func (p priorPairing) historyValue(entry *historyEntry, size int64) basetypes.ListValue {
+	historyType := types.ObjectType{AttrTypes: historyAttrTypes}
+
+	if p.history == nil && entry == nil {
+		return basetypes.NewListNull(historyType)
+	}
+
+	history := p.history
+	if entry != nil {
+		history = append(history[:len(history):len(history)], *entry)
+	}
+
+	if int64(len(history)) > size {
+		history = history[int64(len(history))-max(size, 0):]
+	}
+
+	elements := make([]attr.Value, 0, len(history))
+	for _, entry := range history {
+		result := make(map[string]attr.Value, len(entry.result))
+		for key, value := range entry.result {
+			result[key] = basetypes.NewStringValue(value)
+		}
+
+		elements = append(elements, basetypes.NewObjectValueMust(historyAttrTypes, map[string]attr.Value{
+			"generation": basetypes.NewInt64Value(entry.generation),
+			"result":     basetypes.NewMapValueMust(types.StringType, result),
+		}))
+	}
+
+	return basetypes.NewListValueMust(historyType, elements)
+}
+
+// prior returns what the prior state m holds to pair against the model. When both use key objects everything is
+// rekeyed through the identity of each object, so that changing the separator does not move anything.
+func (m pairModel) prior(model pairModel) priorPairing {
+	prior := priorPairing{
+		exists:     true,
+		result:     make(map[string]string, len(m.Result.Elements())),
+		id:         m.ID,
+		name:       m.Name,
+		indexes:    make(map[string]int64, len(m.Ordered.Elements())),
+		summary:    m.summary(),
+		generation: m.Generation,
+		metadata:   m.AssignmentMetadata,
+
+		rollbackToGeneration: m.RollbackToGeneration,
+		keepers:              m.Keepers,
+		rebalanceTrigger:     m.RebalanceTrigger,
+		valueObjects:         m.ValueObjects,
+		reassignKeys:         make(map[string]string),
+	}
+
+	// A state that was just imported or moved has nothing configured yet, so the triggers of the model are taken
+	// as already acted on rather than as changed, which would pair its result from scratch.
+	triggers := m
+	if m.imported() {
+		triggers = model
+		prior.rollbackToGeneration = model.RollbackToGeneration
+		prior.keepers = model.Keepers
+		prior.rebalanceTrigger = model.RebalanceTrigger
+	}
+
+	for key, nonce := range triggers.ReassignKeys.Elements() {
+		if nonce, ok := nonce.(types.String); ok && !nonce.IsUnknown() && !nonce.IsNull() {
+			prior.reassignKeys[key] = nonce.ValueString()
+		}
+	}
+
+	if !m.History.IsNull() && !m.History.IsUnknown() {
+		prior.history = make([]historyEntry, 0, len(m.History.Elements()))
+	}
+
+	for _, element := range m.History.Elements() {
+		object, ok := element.(types.Object)
+		if !ok || object.IsUnknown() || object.IsNull() {
+			continue
+		}
+
+		generation, ok := object.Attributes()["generation"].(types.Int64)
+		if !ok {
+			continue
+		}
+
+		entry := historyEntry{
+			generation: generation.ValueInt64(),
+			result:     make(map[string]string),
+		}
+
+		if result, ok := object.Attributes()["result"].(types.Map); ok {
+			for key, value := range result.Elements() {
+				if value, ok := value.(types.String); ok {
+					entry.result[key] = value.ValueString()
+				}
+			}
+		}
+
+		prior.history = append(prior.history, entry)
+	}
+
+	for key, value := range m.Result.Elements() {
+		if value, ok := value.(types.String); ok && !value.IsUnknown() && !value.IsNull() {
+			prior.result[key] = value.ValueString()
+		}
+	}
+
+	for _, element := range m.Ordered.Elements() {
+		object, ok := element.(types.Object)
+		if !ok || object.IsUnknown() || object.IsNull() {
+			continue
+		}
+
+		key, ok := object.Attributes()["key"].(types.String)
+		if !ok || key.IsUnknown() || key.IsNull() {
+			continue
+		}
+
+		if index, ok := object.Attributes()["index"].(types.Int64); ok && !index.IsUnknown() && !index.IsNull() {
+			prior.indexes[key.ValueString()] = index.ValueInt64()
+		}
+	}
+
+	if m.KeyObjects.IsNull() || model.KeyObjects.IsNull() || !model.pairable() {
+		return prior
+	}
+
+	priorObjects, _ := m.keyObjects()
+	objects, _ := model.keyObjects()
+
+	keysByIdentity := make(map[string]string, len(objects))
+	for _, object := range objects {
+		keysByIdentity[object.identity] = object.key
+	}
+
+	prior.result = rekey(prior.result, priorObjects, keysByIdentity)
+	prior.indexes = rekey(prior.indexes, priorObjects, keysByIdentity)
+	prior.reassignKeys = rekey(prior.reassignKeys, priorObjects, keysByIdentity)
+
+	for i, entry := range prior.history {
+		prior.history[i].result = rekey(entry.result, priorObjects, keysByIdentity)
+	}
+
+	if !prior.metadata.IsNull() && !prior.metadata.IsUnknown() {
+		prior.metadata = types.MapValueMust(
+			types.ObjectType{AttrTypes: assignmentMetadataAttrTypes},
+			rekey(prior.metadata.Elements(), priorObjects, keysByIdentity),
+		)
+	}
+
+	return prior
+}
+
+// imported returns true when the state m was just imported or moved, which leaves nothing configured in it.
+func (m pairModel) imported() bool {
+	return m.Keys.IsNull() && m.KeyObjects.IsNull() && m.KeyGenerator.IsNull()
+}
+
+// initialPrior returns the initial_result of the model as what is paired against when there is no prior state.
+func (m pairModel) initialPrior() priorPairing {
+	prior := priorPairing{adopting: !m.InitialResult.IsNull()}
+
+	if !prior.adopting {
+		return prior
+	}
+
+	prior.result = make(map[string]string, len(m.InitialResult.Elements()))
+	for key, value := range m.InitialResult.Elements() {
+		if value, ok := value.(types.String); ok && !value.IsUnknown() && !value.IsNull() {
+			prior.result[key] = value.ValueString()
+		}
+	}
+
+	return prior
+}
+
+// rekey returns entries keyed by the prior key objects rekeyed by the current key of the same identity.
+func rekey[V any](entries map[string]V, priorObjects []keyObject, keysByIdentity map[string]string) map[string]V {
+	rekeyed := make(map[string]V, len(entries))
+	for _, object := range priorObjects {
+		value, ok := entries[object.key]
+		if !ok {
+			continue
+		}
+
+		if key, ok := keysByIdentity[object.identity]; ok {
+			rekeyed[key] = value
+		}
+	}
+
+	return rekeyed
+}
+
+// timestamp returns the current time as stored in assignment_metadata.
+func timestamp() types.String {
+	return types.StringValue(time.Now().UTC().Format(time.RFC3339))
+}
+
+// assignmentHash returns the hash of a key being assigned a value, which only changes along with the value.
+func assignmentHash(key, value string) string {
+	encoded, _ := json.Marshal([]string{key, value})
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// resultHash returns the hash of a result, which is the id of a pair without a name.
+func resultHash(result map[string]string) string {
+	encoded, _ := json.Marshal(result)
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// changeSummary describes how the result changed from the prior state.
+type changeSummary struct {
+	addedKeys   types.Set
+	churnRatio  types.Float64
+	freedValues types.Set
+	reassigned  types.Map
+	removedKeys types.Set
+}
+
+// unknownChangeSummary returns a change summary that is entirely unknown.
+func unknownChangeSummary() changeSummary {
+	return changeSummary{
+		addedKeys:   types.SetUnknown(types.StringType),
+		churnRatio:  types.Float64Unknown(),
+		freedValues: types.SetUnknown(types.StringType),
+		reassigned:  types.MapUnknown(types.ObjectType{AttrTypes: reassignmentAttrTypes}),
+		removedKeys: types.SetUnknown(types.StringType),
+	}
+}
+
+// setID sets the id to the name. Without one, the prior id is kept unless it was the prior name, otherwise it is
+// the hash of the result, which is unknown until the result is fully known.
+func (m *pairModel) setID(prior priorPairing) {
+	switch {
+	case !m.Name.IsNull():
+		m.ID = m.Name
+	case !prior.id.IsNull() && !prior.id.IsUnknown() && !prior.id.Equal(prior.name):
+		m.ID = prior.id
+	case fullyKnown(m.Result):
+		m.ID = types.StringValue(resultHash(pairing{mapping: m.Result.Elements()}.known()))
+	default:
+		m.ID = types.StringUnknown()
+	}
+}
+
+// identify sets the identity to the id in state once it is known. The identity is nil when the client does not
+// support identities.
+func identify(ctx context.Context, state interface {
+	GetAttribute(context.Context, path.Path, interface{}) diag.Diagnostics
+}, identity *tfsdk.ResourceIdentity, diagnostics *diag.Diagnostics) {
+	if identity == nil {
+		return
+	}
+
+	var id types.String
+	diagnostics.Append(state.GetAttribute(ctx, path.Root("id"), &id)...)
+	if diagnostics.HasError() || id.IsUnknown() || id.IsNull() {
+		return
+	}
+
+	diagnostics.Append(identity.SetAttribute(ctx, path.Root("id"), id)...)
+}
+
+// summary returns the change summary of the model.
+func (m pairModel) summary() changeSummary {
+	return changeSummary{
+		addedKeys:   m.AddedKeys,
+		churnRatio:  m.ChurnRatio,
+		freedValues: m.FreedValues,
+		reassigned:  m.Reassigned,
+		removedKeys: m.RemovedKeys,
+	}
+}
+
+// setSummary sets the change summary of the model.
+func (m *pairModel) setSummary(summary changeSummary) {
+	m.AddedKeys = summary.addedKeys
+	m.ChurnRatio = summary.churnRatio
+	m.FreedValues = summary.freedValues
+	m.Reassigned = summary.reassigned
+	m.RemovedKeys = summary.removedKeys
+}
+
+// pairable returns false when an input that decides what gets paired, or whether it is allowed to, is unknown
+// as a whole.
+func (m pairModel) pairable() bool {
+	return !m.ApprovedMoves.IsUnknown() &&
+		!m.ChangePolicy.IsUnknown() &&
+		knownElements(m.InitialResult) &&
+		knownElements(m.Keepers) &&
+		!m.Keys.IsUnknown() &&
+		!m.KeyAttributes.IsUnknown() &&
+		fullyKnown(m.KeyGenerator) &&
+		!m.KeyObjects.IsUnknown() &&
+		!m.KeySeparator.IsUnknown() &&
+		!m.LocalityAttributes.IsUnknown() &&
+		m.knownLocality() &&
+		!m.LockedKeys.IsUnknown() &&
+		!m.MaxKeysPerValue.IsUnknown() &&
+		!m.MigrateToHigherTiers.IsUnknown() &&
+		!m.Normalization.IsUnknown() &&
+		!m.Overflow.IsUnknown() &&
+		!m.OverflowTemplate.IsUnknown() &&
+		!m.RebalanceMaxMoves.IsUnknown() &&
+		!m.RebalanceTolerance.IsUnknown() &&
+		!m.RebalanceTrigger.IsUnknown() &&
+		knownElements(m.ReassignKeys) &&
+		!m.RollbackToGeneration.IsUnknown() &&
+		!m.TierMigrationMaxMoves.IsUnknown() &&
+		fullyKnown(m.ValueGenerator) &&
+		!m.ValueObjects.IsUnknown() &&
+		m.knownTiers() &&
+		!m.Values.IsUnknown()
+}
+
+// knownLocality returns true when the locality_attributes of every element of value_objects are known.
+func (m pairModel) knownLocality() bool {
+	if m.LocalityAttributes.IsNull() {
+		return true
+	}
+
+	for _, object := range m.ValueObjects.Elements() {
+		for _, value := range m.locality(object) {
+			if value.IsUnknown() {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// locality returns what the given element of value_objects has for each of locality_attributes, in order.
+func (m pairModel) locality(element attr.Value) []basetypes.StringValue {
+	attributes := make([]basetypes.StringValue, 0, len(m.LocalityAttributes.Elements()))
+
+	object, ok := element.(types.Map)
+	if !ok || object.IsUnknown() {
+		for range m.LocalityAttributes.Elements() {
+			attributes = append(attributes, basetypes.NewStringUnknown())
+		}
+
+		return attributes
+	}
+
+	for _, name := range m.LocalityAttributes.Elements() {
+		name, ok := name.(types.String)
+		if !ok {
+			attributes = append(attributes, basetypes.NewStringNull())
+			continue
+		}
+
+		value, ok := object.Elements()[name.ValueString()].(types.String)
+		if !ok {
+			value = basetypes.NewStringNull()
+		}
+
+		attributes = append(attributes, value)
+	}
+
+	return attributes
+}
+
+// knownTiers returns true when value_tiers and each of its tiers are known, though the values in them may not be.
+func (m pairModel) knownTiers() bool {
+	if m.ValueTiers.IsUnknown() {
+		return false
+	}
+
+	for _, tier := range m.ValueTiers.Elements() {
+		if tier.IsUnknown() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resultObjectType is the element type of the result_objects attribute.
+var resultObjectType = types.MapType{ElemType: types.StringType}
+
+// options returns how the model wants keys and values, as returned by elements, paired.
+func (m pairModel) options(keys, values []basetypes.StringValue) pairOptions {
+	options := pairOptions{
+		normalization: m.Normalization.ValueString(),
+		keysPerValue:  int(m.MaxKeysPerValue.ValueInt64()),
+	}
+
+	// Sharing spreads keys evenly by making just enough room on every value for all of them.
+	if m.Overflow.ValueString() == overflowShare && len(values) > 0 {
+		options.keysPerValue = max(options.keysPerValue, (len(keys)+len(values)-1)/len(values))
+	}
+
+	if !m.LocalityAttributes.IsNull() {
+		options.valueLocality = make(map[string][]basetypes.StringValue, len(m.ValueObjects.Elements()))
+		for id, object := range m.ValueObjects.Elements() {
+			options.valueLocality[id] = m.locality(object)
+		}
+	}
+
+	if m.ValueTiers.IsNull() {
+		return options
+	}
+
+	options.tiers = make(map[string]int)
+
+	// Generated values are not in any tier, so they go in a tier of their own below every other.
+	for _, value := range values {
+		if !value.IsUnknown() {
+			options.tiers[value.ValueString()] = len(m.ValueTiers.Elements())
+		}
+	}
+
+	for tier, values := range m.ValueTiers.Elements() {
+		values, ok := values.(types.Set)
+		if !ok {
+			continue
+		}
+
+		for _, value := range values.Elements() {
+			if value.IsUnknown() {
+				options.unknownTiers = append(options.unknownTiers, tier)
+				continue
+			}
+
+			if value, ok := value.(basetypes.StringValuable); ok {
+				if value, diags := value.ToStringValue(context.Background()); !diags.HasError() {
+					options.tiers[value.ValueString()] = tier
+				}
+			}
+		}
+	}
+
+	return options
+}
+
+// pairOptions changes how pair matches up and assigns keys and values.
+type pairOptions struct {
+	// normalization is the mode used to decide whether two keys or two values are the same.
+	normalization string
+
+	// keysPerValue is the number of keys each value can be assigned to, where anything less than one means one.
+	keysPerValue int
+
+	// tiers is the tier of each known value and unknownTiers the tier of each unknown value in the order they
+	// are given, where zero is the highest and the default. New keys are assigned values in the highest tier
+	// that has room.
+	tiers        map[string]int
+	unknownTiers []int
+
+	// locality is what each key that lost its value had for the locality attributes and valueLocality is what
+	// each value has for them, in order. A new key with locality is assigned a value that shares the longest
+	// run of leading locality attributes first.
+	locality      map[string][]basetypes.StringValue
+	valueLocality map[string][]basetypes.StringValue
+
+	// excluded is the value each key being reassigned gave up, which it is not assigned again.
+	excluded map[string]string
+}
+
+// capacity returns the number of keys each value can be assigned to.
+func (o pairOptions) capacity() int {
+	return max(o.keysPerValue, 1)
+}
+
+// before returns true when key should rather be assigned value than other, given how many keys each value is
+// assigned to.
+func (o pairOptions) before(key, value, other string, used map[string]int) bool {
+	if o.tier(value) != o.tier(other) {
+		return o.tier(value) < o.tier(other)
+	}
+
+	if shared, otherShared := o.shared(key, value), o.shared(key, other); shared != otherShared {
+		return shared > otherShared
+	}
+
+	return used[value] < used[other]
+}
+
+// tier returns the tier of a known value.
+func (o pairOptions) tier(value string) int {
+	return o.tiers[value]
+}
+
+// shared returns how many of the leading locality attributes that key had the value has as well.
+func (o pairOptions) shared(key, value string) int {
+	wanted, ok := o.locality[key]
+	if !ok {
+		return 0
+	}
+
+	attributes := o.valueLocality[value]
+
+	for i := range wanted {
+		if i >= len(attributes) || wanted[i].IsNull() || wanted[i].IsUnknown() || !wanted[i].Equal(attributes[i]) {
+			return i
+		}
+	}
+
+	return len(wanted)
+}
+
+// unknownTier returns the tier of the i-th unknown value.
+func (o pairOptions) unknownTier(i int) int {
+	if i < len(o.unknownTiers) {
+		return o.unknownTiers[i]
+	}
+
+	return 0
+}
+
+// tierValues returns the known values grouped by tier, highest first, keeping their order within each tier.
+func (o pairOptions) tierValues(values []basetypes.StringValue) [][]basetypes.StringValue {
+	byTier := make(map[int][]basetypes.StringValue)
+	tiers := make([]int, 0)
+
+	for _, value := range values {
+		if value.IsUnknown() {
+			continue
+		}
+
+		tier := o.tier(value.ValueString())
+		if _, ok := byTier[tier]; !ok {
+			tiers = append(tiers, tier)
+		}
+
+		byTier[tier] = append(byTier[tier], value)
+	}
+
+	sort.Ints(tiers)
+
+	grouped := make([][]basetypes.StringValue, len(tiers))
+	for i, tier := range tiers {
+		grouped[i] = byTier[tier]
+	}
+
+	return grouped
+}
+
+// maxElements is the most keys or values that can be configured.
+const maxElements = 100000
+
+// elementValidators returns the validators of a set of keys or values, which reject null or empty elements and
+// sets that are too large.
+func elementValidators() []validator.Set {
+	return []validator.Set{
+		setvalidator.NoNullValues(),
+		setvalidator.SizeAtMost(maxElements),
+		setvalidator.ValueStringsAre(stringvalidator.LengthAtLeast(1)),
+	}
+}
+
+// validatePattern adds an error for every element that does not match pattern, or a single one at the path of
+// pattern when it is not a valid regular expression.
+func validatePattern(name string, elements []normalizedElement, pattern types.String, at path.Path, diagnostics *diag.Diagnostics) {
+	if pattern.IsNull() || pattern.IsUnknown() {
+		return
+	}
+
+	expression, err := regexp.Compile(pattern.ValueString())
+	if err != nil {
+		diagnostics.AddAttributeError(
+			at,
+			fmt.Sprintf("Invalid %s Pattern", name),
+			fmt.Sprintf("%q is not a valid regular expression: %s.", pattern.ValueString(), err),
+		)
+		return
+	}
+
+	for _, element := range elements {
+		value, ok := element.value.(basetypes.StringValuable)
+		if !ok || value.IsUnknown() || value.IsNull() {
+			continue
+		}
+
+		stringValue, diags := value.ToStringValue(context.Background())
+		if diags.HasError() {
+			continue
+		}
+
+		if !expression.MatchString(stringValue.ValueString()) {
+			diagnostics.AddAttributeError(
+				element.path,
+				fmt.Sprintf("%s Does Not Match Pattern", name),
+				fmt.Sprintf("%q does not match %q.", stringValue.ValueString(), pattern.ValueString()),
+			)
+		}
+	}
+}
+
+// normalizedElement is a key or value to check with validateNormalizedUnique along with where it came from.
+type normalizedElement struct {
+	path  path.Path
+	value attr.Value
+}
+
+// validateNormalizedUnique adds an error for every element that is the same as an earlier one once normalized
+// in the given mode.
+func validateNormalizedUnique(name string, elements []normalizedElement, mode string, diagnostics *diag.Diagnostics) {
+	if mode == "" || mode == normalizationNone {
+		return
+	}
+
+	seen := make(map[string]string)
+	for _, element := range elements {
+		value, ok := element.value.(basetypes.StringValuable)
+		if !ok || value.IsUnknown() || value.IsNull() {
+			continue
+		}
+
+		stringValue, diags := value.ToStringValue(context.Background())
+		if diags.HasError() {
+			continue
+		}
+
+		normalized := normalize(mode, stringValue.ValueString())
+		if other, ok := seen[normalized]; ok {
+			diagnostics.AddAttributeError(
+				element.path,
+				fmt.Sprintf("Duplicate %s After Normalization", name),
+				fmt.Sprintf("%q and %q are the same once normalized as %q, remove one of them or change normalization.", other, stringValue.ValueString(), normalized),
+			)
+			continue
+		}
+
+		seen[normalized] = stringValue.ValueString()
+	}
+}
+
+// assignmentAttrTypes are the attribute types of each element of the assignments attribute.
+var assignmentAttrTypes = map[string]attr.Type{
+	"key":   types.StringType,
+	"value": types.StringType,
+}
+
+// assignmentMetadataAttrTypes are the attribute types of each element of the assignment_metadata attribute.
+var assignmentMetadataAttrTypes = map[string]attr.Type{
+	"assigned_at": types.StringType,
+	"generation":  types.Int64Type,
+	"hash":        types.StringType,
+}
+
+// historyAttrTypes are the attribute types of each element of the history attribute.
+var historyAttrTypes = map[string]attr.Type{
+	"generation": types.Int64Type,
+	"result":     types.MapType{ElemType: types.StringType},
+}
+
+// reassignmentAttrTypes are the attribute types of each element of the reassigned attribute.
+var reassignmentAttrTypes = map[string]attr.Type{
+	"from": types.StringType,
+	"to":   types.StringType,
+}
+
+// groupedType is the element type of the grouped attribute.
+var groupedType = types.ListType{ElemType: types.StringType}
+
+// orderedAttrTypes are the attribute types of each element of the ordered attribute.
+var orderedAttrTypes = map[string]attr.Type{
+	"index": types.Int64Type,
+	"key":   types.StringType,
+	"value": types.StringType,
+}
+
+// pairing holds the mapping produced by pair along with the bookkeeping needed to tell which parts of it are
+// affected by unknown keys or values.
+type pairing struct {
+	mapping  map[string]attr.Value
+	retained map[string]bool
+
+	keys          int
+	keysUnknown   int
+	values        int
+	valuesUnknown int
+
+	// newKeys is the number of known keys that did not keep a value from the existing result and valuesFree is
+	// the number of known values left over once those existing assignments were kept.
+	newKeys    int
+	valuesFree int
+
+	// unknownValuesLeft and unassignedValues describe what was left over after assigning values to known keys.
+	unknownValuesLeft int
+	unassignedValues  int
+
+	// unassigned holds the known keys that were left without a value, in the order they were given.
+	unassigned []string
+
+	// normalization is the mode the keys and values were paired in, which decides whether an existing assignment
+	// is the same as one in the mapping that is spelled differently.
+	normalization string
+}
+
+func pairStable(existingResult map[string]string, keys, values []basetypes.StringValue) basetypes.MapValue {
+	return pair(existingResult, keys, values, pairOptions{}).result()
+}
+
+func pair(existingResult map[string]string, keys, values []basetypes.StringValue, options pairOptions) pairing {
+	if options.normalization != "" && options.normalization != normalizationNone {
+		return pairNormalized(existingResult, keys, values, options)
+	}
+
+	// First up, make a map each of keys and values to allow for easy logic below.
+	keyMapping := make(map[string]bool)
+	keysUnknown := 0
+	valueMapping := make(map[string]bool)
+	valuesUnknown := 0
+
+	for _, key := range keys {
+		if key.IsUnknown() {
+			keysUnknown += 1
+		} else {
+			keyMapping[key.ValueString()] = true
+		}
+	}
+
+	for _, value := range values {
+		if value.IsUnknown() {
+			valuesUnknown += 1
+		} else {
+			valueMapping[value.ValueString()] = true
+		}
+	}
+
+	// Each value has room for capacity keys, so the bookkeeping below counts those slots rather than values.
+	capacity := options.capacity()
+
+	p := pairing{
+		retained:      make(map[string]bool),
+		keys:          len(keys),
+		keysUnknown:   keysUnknown,
+		values:        len(values) * capacity,
+		valuesUnknown: valuesUnknown,
+	}
+
+	// Given an existing mapping, determine which of those should persist. If a key
+	// is no longer present, no value needs to be assigned. However, if a value is
+	// no longer present or has too many keys, a new one needs to be assigned. The
+	// latter is easily achieved by leaving it out of the trimmed mapping and then
+	// allowing the logic below for new keys take care of that.
+	finalMapping := make(map[string]attr.Value)
+	valuesUsed := make(map[string]int)
+	slotsUsed := 0
+
+	// Sorting makes the outcome deterministic should a value have more existing keys than it has room for.
+	existingKeys := make([]string, 0, len(existingResult))
+	for key := range existingResult {
+		existingKeys = append(existingKeys, key)
+	}
+
+	sort.Strings(existingKeys)
+
+	for _, key := range existingKeys {
+		value := existingResult[key]
+
+		if _, ok := keyMapping[key]; !ok {
+			continue
+		}
+
+		if _, ok := valueMapping[value]; !ok {
+			continue
+		}
+
+		if valuesUsed[value] >= capacity {
+			continue
+		}
+
+		finalMapping[key] = basetypes.NewStringValue(value)
+		valuesUsed[value] += 1
+		slotsUsed += 1
+		p.retained[key] = true
+	}
+
+	p.newKeys = len(keyMapping) - len(p.retained)
+	p.valuesFree = len(valueMapping)*capacity - slotsUsed
+
+	// Unknown values are tracked by tier, as a key could end up on one of them rather than on a known value in a
+	// lower tier.
+	unknownSlotsLeft := valuesUnknown * capacity
+	unknownSlots := make(map[int]int)
+	unknown := 0
+
+	for _, value := range values {
+		if value.IsUnknown() {
+			unknownSlots[options.unknownTier(unknown)] += capacity
+			unknown += 1
+		}
+	}
+
+	// Next, find new values for new keys (or existing ones who lost their value),
+	// going with the least used value that has room in the highest tier that does,
+	// among those sharing the most locality with the value the key lost, the first
+	// of them on a tie. Values with room are kept in that order, so that only keys
+	// with locality need to look through all of them.
+	free := make(freeValues, 0, len(values))
+	for i, value := range values {
+		if !value.IsUnknown() && valuesUsed[value.ValueString()] < capacity {
+			free = append(free, freeValue{tier: options.tier(value.ValueString()), used: valuesUsed[value.ValueString()], index: i})
+		}
+	}
+
+	heap.Init(&free)
+
+	for _, key := range keys {
+		if key.IsUnknown() {
+			continue
+		}
+
+		if _, ok := finalMapping[key.ValueString()]; ok {
+			continue
+		}
+
+		excluded, excluding := options.excluded[key.ValueString()]
+
+		least := -1
+		if _, ok := options.locality[key.ValueString()]; ok {
+			for i, value := range values {
+				if value.IsUnknown() || valuesUsed[value.ValueString()] >= capacity || (excluding && excluded == value.ValueString()) {
+					continue
+				}
+
+				if least < 0 || options.before(key.ValueString(), value.ValueString(), values[least].ValueString(), valuesUsed) {
+					least = i
+				}
+			}
+		} else {
+			least = free.first(values, valuesUsed, capacity, func(value string) bool {
+				return excluding && excluded == value
+			})
+		}
+
+		unknownTier, unknownFound := 0, false
+		for tier, slots := range unknownSlots {
+			if slots > 0 && (!unknownFound || tier < unknownTier) {
+				unknownTier, unknownFound = tier, true
+			}
+		}
+
+		if least >= 0 && (!unknownFound || unknownTier >= options.tier(values[least].ValueString())) {
+			value := values[least].ValueString()
+
+			finalMapping[key.ValueString()] = values[least]
+			valuesUsed[value] += 1
+			slotsUsed += 1
+
+			if valuesUsed[value] < capacity {
+				heap.Push(&free, freeValue{tier: options.tier(value), used: valuesUsed[value], index: least})
+			}
+
+			continue
+		}
+
+		if unknownFound {
+			finalMapping[key.ValueString()] = basetypes.NewStringUnknown()
+			unknownSlots[unknownTier] -= 1
+			unknownSlotsLeft -= 1
+			continue
+		}
+
+		p.unassigned = append(p.unassigned, key.ValueString())
+	}
+
+	p.mapping = finalMapping
+	p.unknownValuesLeft = unknownSlotsLeft
+	p.unassignedValues = len(valueMapping)*capacity - slotsUsed
+
+	return p
+}
+
+// freeValue is a known value with room, at index in the values being paired, along with its tier and how many
+// keys it was assigned to when it was added to freeValues.
+type freeValue struct {
+	tier, used, index int
+}
+
+// freeValues is a heap of the known values with room, highest tier first, then least used, then in the order
+// they are given. A value is added again each time it is assigned a key, leaving the earlier entry outdated.
+type freeValues []freeValue
+
+func (f freeValues) Len() int {
+	return len(f)
+}
+
+func (f freeValues) Less(i, j int) bool {
+	if f[i].tier != f[j].tier {
+		return f[i].tier < f[j].tier
+	}
+
+	if f[i].used != f[j].used {
+		return f[i].used < f[j].used
+	}
+
+	return f[i].index < f[j].index
+}
+
+func (f freeValues) Swap(i, j int) {
+	f[i], f[j] = f[j], f[i]
+}
+
+func (f *freeValues) Push(x any) {
+	if value, ok := x.(freeValue); ok {
+		*f = append(*f, value)
+	}
+}
+
+func (f *freeValues) Pop() any {
+	old := *f
+	value := old[len(old)-1]
+	*f = old[:len(old)-1]
+
+	return value
+}
+
+// first returns the index of the first of the values with room that is not excluded, or -1 if there is none,
+// dropping outdated entries along the way.
+func (f *freeValues) first(values []basetypes.StringValue, used map[string]int, capacity int, excluded func(string) bool) int {
+	var skipped []freeValue
+
+	defer func() {
+		for _, value := range skipped {
+			heap.Push(f, value)
+		}
+	}()
+
+	for f.Len() > 0 {
+		top := (*f)[0]
+		value := values[top.index].ValueString()
+
+		if top.used != used[value] || used[value] >= capacity {
+			heap.Pop(f)
+			continue
+		}
+
+		if excluded(value) {
+			skipped = append(skipped, top)
+			heap.Pop(f)
+			continue
+		}
+
+		return top.index
+	}
+
+	return -1
+}
+
+// rebalance moves keys from the most to the least used values of each tier for as long as the spread between
+// the most and least used values of the tier is more than tolerance and a move narrows it, making at most
+// maxMoves moves across all tiers unless that is negative. Keys are never moved between tiers. Only keys for
+// which movable returns true are moved, the last of them in sorted order first. It assumes every key and value
+// is known.
+func rebalance(p pairing, values []basetypes.StringValue, options pairOptions, tolerance, maxMoves int, movable func(string) bool) pairing {
+	capacity := options.capacity()
+
+	used := make(map[string]int, len(values))
+	keysByValue := make(map[string][]string, len(values))
+
+	for key, value := range p.mapping {
+		if value, ok := value.(basetypes.StringValue); ok && !value.IsUnknown() {
+			used[value.ValueString()] += 1
+			keysByValue[value.ValueString()] = append(keysByValue[value.ValueString()], key)
+		}
+	}
+
+	for _, keys := range keysByValue {
+		sort.Strings(keys)
+	}
+
+	// lastMovable returns the index of the key of value to move, or -1 if none can be.
+	lastMovable := func(value string) int {
+		keys := keysByValue[value]
+		for i := len(keys) - 1; i >= 0; i-- {
+			if movable(keys[i]) {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	mapping := make(map[string]attr.Value, len(p.mapping))
+	maps.Copy(mapping, p.mapping)
+
+	retained := make(map[string]bool, len(p.retained))
+	maps.Copy(retained, p.retained)
+
+	moves := 0
+
+	for _, values := range options.tierValues(values) {
+		moves = rebalanceTier(values, keysByValue, used, mapping, retained, capacity, tolerance, maxMoves, moves, lastMovable)
 	}
 
-	// Next, find new values for new keys (or existing ones who lost their value).
-	for _, key := range keys {
-		if key.IsUnknown() {
-			continue
+	p.mapping = mapping
+	p.retained = retained
+
+	return p
+}
+
+// rebalanceTier makes the moves of rebalance within a single tier of values, given how many moves were already
+// made, and returns the total number of moves made.
+func rebalanceTier(values []basetypes.StringValue, keysByValue map[string][]string, used map[string]int, mapping map[string]attr.Value, retained map[string]bool, capacity, tolerance, maxMoves, moves int, lastMovable func(string) int) int {
+	for ; maxMoves < 0 || moves < maxMoves; moves++ {
+		if len(values) == 0 {
+			break
+		}
+
+		most, least := values[0].ValueString(), values[0].ValueString()
+		var source, target *string
+
+		for _, value := range values {
+			value := value.ValueString()
+
+			if used[value] > used[most] {
+				most = value
+			}
+
+			if used[value] < used[least] {
+				least = value
+			}
+
+			if lastMovable(value) >= 0 && (source == nil || used[value] > used[*source]) {
+				source = &value
+			}
+
+			if used[value] < capacity && (target == nil || used[value] < used[*target]) {
+				target = &value
+			}
 		}
 
-		if _, ok := finalMapping[key.ValueString()]; ok {
-			continue
+		if used[most]-used[least] <= tolerance {
+			break
+		}
+
+		if source == nil || target == nil || used[*source]-used[*target] <= 1 {
+			break
+		}
+
+		i := lastMovable(*source)
+		key := keysByValue[*source][i]
+
+		keysByValue[*source] = append(keysByValue[*source][:i], keysByValue[*source][i+1:]...)
+		keysByValue[*target] = append(keysByValue[*target], key)
+		sort.Strings(keysByValue[*target])
+
+		used[*source] -= 1
+		used[*target] += 1
+
+		mapping[key] = basetypes.NewStringValue(*target)
+		retained[key] = false
+	}
+
+	return moves
+}
+
+// migrate moves keys from values in lower tiers to the least used values with room in the highest tier that has
+// any, those in the lowest tier first and the last of them in sorted order first, making at most maxMoves moves
+// unless that is negative. Only keys for which movable returns true are moved. It assumes every key and value is
+// known.
+func migrate(p pairing, values []basetypes.StringValue, options pairOptions, maxMoves int, movable func(string) bool) pairing {
+	capacity := options.capacity()
+
+	used := make(map[string]int, len(values))
+	keys := make([]string, 0, len(p.mapping))
+
+	for key, value := range p.mapping {
+		if value, ok := value.(basetypes.StringValue); ok && !value.IsUnknown() {
+			used[value.ValueString()] += 1
+			keys = append(keys, key)
 		}
+	}
+
+	sort.Strings(keys)
+
+	mapping := make(map[string]attr.Value, len(p.mapping))
+	maps.Copy(mapping, p.mapping)
+
+	retained := make(map[string]bool, len(p.retained))
+	maps.Copy(retained, p.retained)
+
+	for moves := 0; maxMoves < 0 || moves < maxMoves; moves++ {
+		var target *string
 
 		for _, value := range values {
-			if value.IsUnknown() {
+			value := value.ValueString()
+
+			if used[value] >= capacity {
 				continue
 			}
 
-			if _, ok := valuesUsed[value.ValueString()]; ok {
-				continue
+			if target == nil || options.tier(value) < options.tier(*target) ||
+				options.tier(value) == options.tier(*target) && used[value] < used[*target] {
+				target = &value
 			}
+		}
 
-			finalMapping[key.ValueString()] = value
-			valuesUsed[value.ValueString()] = true
+		if target == nil {
 			break
 		}
 
-		if valuesUnknown > 0 {
-			if _, ok := finalMapping[key.ValueString()]; !ok {
-				finalMapping[key.ValueString()] = basetypes.NewStringUnknown()
-				valuesUnknown -= 1
+		// valueOf returns the value the i-th key is currently assigned.
+		valueOf := func(i int) string {
+			value, ok := mapping[keys[i]].(basetypes.StringValue)
+			if !ok {
+				return ""
+			}
+
+			return value.ValueString()
+		}
+
+		source := -1
+		for i := len(keys) - 1; i >= 0; i-- {
+			if options.tier(valueOf(i)) <= options.tier(*target) || !movable(keys[i]) {
 				continue
 			}
+
+			if source < 0 || options.tier(valueOf(i)) > options.tier(valueOf(source)) {
+				source = i
+			}
+		}
+
+		if source < 0 {
+			break
+		}
+
+		used[valueOf(source)] -= 1
+		used[*target] += 1
+
+		mapping[keys[source]] = basetypes.NewStringValue(*target)
+		retained[keys[source]] = false
+	}
+
+	p.mapping = mapping
+	p.retained = retained
+
+	return p
+}
+
+// pairNormalized pairs the normalized forms of keys and values, so that an existing assignment is kept when
+// only the formatting of its key or value changed. The mapping uses keys and values as currently spelled.
+func pairNormalized(existingResult map[string]string, keys, values []basetypes.StringValue, options pairOptions) pairing {
+	normalizedOptions := options
+	normalizedOptions.normalization = normalizationNone
+
+	if options.tiers != nil {
+		normalizedOptions.tiers = make(map[string]int, len(options.tiers))
+		for value, tier := range options.tiers {
+			normalizedOptions.tiers[normalize(options.normalization, value)] = tier
+		}
+	}
+
+	if options.locality != nil {
+		normalizedOptions.locality = make(map[string][]basetypes.StringValue, len(options.locality))
+		for key, locality := range options.locality {
+			normalizedOptions.locality[normalize(options.normalization, key)] = locality
+		}
+	}
+
+	if options.valueLocality != nil {
+		normalizedOptions.valueLocality = make(map[string][]basetypes.StringValue, len(options.valueLocality))
+		for value, locality := range options.valueLocality {
+			normalizedOptions.valueLocality[normalize(options.normalization, value)] = locality
+		}
+	}
+
+	if options.excluded != nil {
+		normalizedOptions.excluded = make(map[string]string, len(options.excluded))
+		for key, value := range options.excluded {
+			normalizedOptions.excluded[normalize(options.normalization, key)] = normalize(options.normalization, value)
+		}
+	}
+
+	normalizeAll := func(elements []basetypes.StringValue) ([]basetypes.StringValue, map[string]string) {
+		normalized := make([]basetypes.StringValue, len(elements))
+		spellings := make(map[string]string, len(elements))
+
+		for i, element := range elements {
+			normalized[i] = element
+
+			if !element.IsUnknown() {
+				normalized[i] = basetypes.NewStringValue(normalize(options.normalization, element.ValueString()))
+				spellings[normalized[i].ValueString()] = element.ValueString()
+			}
+		}
+
+		return normalized, spellings
+	}
+
+	normalizedKeys, keySpellings := normalizeAll(keys)
+	normalizedValues, valueSpellings := normalizeAll(values)
+
+	// Sorting makes the outcome deterministic should several existing keys normalize the same.
+	existingKeys := make([]string, 0, len(existingResult))
+	for key := range existingResult {
+		existingKeys = append(existingKeys, key)
+	}
+
+	sort.Strings(existingKeys)
+
+	normalizedExistingResult := make(map[string]string, len(existingResult))
+	for _, key := range existingKeys {
+		normalizedKey := normalize(options.normalization, key)
+
+		if _, ok := normalizedExistingResult[normalizedKey]; !ok {
+			normalizedExistingResult[normalizedKey] = normalize(options.normalization, existingResult[key])
+		}
+	}
+
+	p := pair(normalizedExistingResult, normalizedKeys, normalizedValues, normalizedOptions)
+
+	mapping := make(map[string]attr.Value, len(p.mapping))
+	retained := make(map[string]bool, len(p.retained))
+
+	for key, value := range p.mapping {
+		if value, ok := value.(basetypes.StringValue); ok && !value.IsUnknown() {
+			mapping[keySpellings[key]] = basetypes.NewStringValue(valueSpellings[value.ValueString()])
+		} else {
+			mapping[keySpellings[key]] = value
+		}
+
+		if p.retained[key] {
+			retained[keySpellings[key]] = true
+		}
+	}
+
+	unassigned := make([]string, 0, len(p.unassigned))
+	for _, key := range p.unassigned {
+		unassigned = append(unassigned, keySpellings[key])
+	}
+
+	p.mapping = mapping
+	p.retained = retained
+	p.unassigned = unassigned
+	p.normalization = options.normalization
+
+	return p
+}
+
+// same returns true when a and b are the same once normalized in the mode the mapping was paired in.
+func (p pairing) same(a, b string) bool {
+	return normalize(p.normalization, a) == normalize(p.normalization, b)
+}
+
+// normalizedKeys returns each key of the existing result by its normalized form.
+func (p pairing) normalizedKeys(existingResult map[string]string) map[string]string {
+	// Sorting makes the outcome deterministic should several existing keys normalize the same, the same way
+	// pairNormalized does.
+	sortedKeys := make([]string, 0, len(existingResult))
+	for key := range existingResult {
+		sortedKeys = append(sortedKeys, key)
+	}
+
+	sort.Strings(sortedKeys)
+
+	normalized := make(map[string]string, len(existingResult))
+	for _, key := range sortedKeys {
+		if _, ok := normalized[normalize(p.normalization, key)]; !ok {
+			normalized[normalize(p.normalization, key)] = key
+		}
+	}
+
+	return normalized
+}
+
+// existingKeys returns the key of the existing result that each key of the mapping is, which is only spelled
+// differently when it was respelled under normalization. Keys that are not in the existing result are left out.
+func (p pairing) existingKeys(existingResult map[string]string) map[string]string {
+	normalized := p.normalizedKeys(existingResult)
+
+	keys := make(map[string]string, len(p.mapping))
+	for key := range p.mapping {
+		if existing, ok := normalized[normalize(p.normalization, key)]; ok {
+			keys[key] = existing
 		}
 	}
 
+	return keys
+}
+
+// unassignedKeys returns the keys left without a value as a set.
+func (p pairing) unassignedKeys() basetypes.SetValue {
+	elements := make([]attr.Value, 0, len(p.unassigned))
+	for _, key := range p.unassigned {
+		elements = append(elements, basetypes.NewStringValue(key))
+	}
+
+	return basetypes.NewSetValueMust(types.StringType, elements)
+}
+
+// result returns the mapping as a map of keys to values.
+func (p pairing) result() basetypes.MapValue {
 	// If at the end of all of this, we have some unknown keys that would map to
 	// some unknown values, we sadly have to return an entirely unknown result due
 	// the requirement that maps have string values.
-	if keysUnknown > 0 && (valuesUnknown > 0 || (len(valueMapping)-len(valuesUsed) > 0)) {
+	if p.keysUnknown > 0 && (p.unknownValuesLeft > 0 || p.unassignedValues > 0) {
 		return basetypes.NewMapUnknown(types.StringType)
 	}
 
-	return basetypes.NewMapValueMust(types.StringType, finalMapping)
+	return basetypes.NewMapValueMust(types.StringType, p.mapping)
+}
+
+// known returns the known assignments of the mapping.
+func (p pairing) known() map[string]string {
+	known := make(map[string]string, len(p.mapping))
+	for key, value := range p.mapping {
+		if value, ok := value.(basetypes.StringValue); ok && !value.IsUnknown() {
+			known[key] = value.ValueString()
+		}
+	}
+
+	return known
+}
+
+// unchanged returns true when the mapping is the existing result, other than in how keys and values are spelled
+// under normalization.
+func (p pairing) unchanged(existingResult map[string]string) bool {
+	if len(p.mapping) != len(existingResult) {
+		return false
+	}
+
+	existingKeys := p.existingKeys(existingResult)
+
+	for key, value := range p.mapping {
+		existing, ok := existingKeys[key]
+		if !ok {
+			return false
+		}
+
+		value, ok := value.(basetypes.StringValue)
+		if !ok || value.IsUnknown() || !p.same(value.ValueString(), existingResult[existing]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reassigned returns a description of each key of the existing result that the mapping assigns a different
+// value, sorted by key.
+func (p pairing) reassigned(existingResult map[string]string) []string {
+	if p.result().IsUnknown() {
+		return nil
+	}
+
+	var reassigned []string
+	for key, existingKey := range p.existingKeys(existingResult) {
+		existing := existingResult[existingKey]
+
+		value, ok := p.mapping[key].(basetypes.StringValue)
+		if !ok || (!value.IsUnknown() && p.same(value.ValueString(), existing)) {
+			continue
+		}
+
+		to := "(known after apply)"
+		if !value.IsUnknown() {
+			to = fmt.Sprintf("%q", value.ValueString())
+		}
+
+		reassigned = append(reassigned, fmt.Sprintf("  %q: %q -> %s", key, existing, to))
+	}
+
+	sort.Strings(reassigned)
+
+	return reassigned
+}
+
+// summary returns how the mapping differs from the existing result. It assumes the mapping is known, though
+// values in it may not be, in which case everything that depends on them is unknown.
+func (p pairing) summary(existingResult map[string]string) changeSummary {
+	summary := unknownChangeSummary()
+
+	// Keys and values only respelled under normalization are the same as the existing ones.
+	existingKeys := p.existingKeys(existingResult)
+
+	kept := make(map[string]bool, len(existingKeys))
+	for _, existing := range existingKeys {
+		kept[existing] = true
+	}
+
+	var addedKeys, removedKeys []attr.Value
+	for key := range p.mapping {
+		if _, ok := existingKeys[key]; !ok {
+			addedKeys = append(addedKeys, basetypes.NewStringValue(key))
+		}
+	}
+
+	for key := range existingResult {
+		if !kept[key] {
+			removedKeys = append(removedKeys, basetypes.NewStringValue(key))
+		}
+	}
+
+	summary.addedKeys = basetypes.NewSetValueMust(types.StringType, addedKeys)
+	summary.removedKeys = basetypes.NewSetValueMust(types.StringType, removedKeys)
+
+	// An unknown value could still turn out to be any value that is not assigned yet.
+	if p.valuesPending() {
+		return summary
+	}
+
+	assigned := make(map[string]bool, len(p.mapping))
+	for _, value := range p.mapping {
+		if value, ok := value.(basetypes.StringValue); ok {
+			assigned[normalize(p.normalization, value.ValueString())] = true
+		}
+	}
+
+	reassigned := make(map[string]attr.Value)
+	freedValues := make(map[string]attr.Value)
+
+	for key, existingKey := range existingKeys {
+		existing := existingResult[existingKey]
+
+		if value, ok := p.mapping[key].(basetypes.StringValue); ok && !p.same(value.ValueString(), existing) {
+			reassigned[key] = basetypes.NewObjectValueMust(reassignmentAttrTypes, map[string]attr.Value{
+				"from": basetypes.NewStringValue(existing),
+				"to":   value,
+			})
+		}
+	}
+
+	for _, existing := range existingResult {
+		if !assigned[normalize(p.normalization, existing)] {
+			freedValues[existing] = basetypes.NewStringValue(existing)
+		}
+	}
+
+	freedValuesList := make([]attr.Value, 0, len(freedValues))
+	for _, value := range freedValues {
+		freedValuesList = append(freedValuesList, value)
+	}
+
+	summary.freedValues = basetypes.NewSetValueMust(types.StringType, freedValuesList)
+	summary.reassigned = basetypes.NewMapValueMust(types.ObjectType{AttrTypes: reassignmentAttrTypes}, reassigned)
+
+	// Churn is the share of every key in either result that was added, removed or reassigned.
+	changed := len(addedKeys) + len(removedKeys) + len(reassigned)
+	total := len(p.mapping) + len(removedKeys)
+
+	summary.churnRatio = basetypes.NewFloat64Value(0)
+	if total > 0 {
+		summary.churnRatio = basetypes.NewFloat64Value(float64(changed) / float64(total))
+	}
+
+	return summary
+}
+
+// metadata returns the metadata of each key in the mapping along with the generation of a change from the
+// prior state to the mapping. Keys that keep their value keep their metadata, every other key is given the new
+// generation, the hash of its value and the now timestamp. It assumes the mapping is known and differs from the
+// prior result, though values in it may not be known, in which case the generation is not known either.
+func (p pairing) metadata(prior priorPairing, now types.String) (basetypes.MapValue, basetypes.Int64Value) {
+	metadataType := types.ObjectType{AttrTypes: assignmentMetadataAttrTypes}
+
+	// An unknown value could still turn out to be the one a key already has, in which case nothing changes.
+	generation := basetypes.NewInt64Unknown()
+	if !p.valuesPending() {
+		generation = basetypes.NewInt64Value(prior.generation.ValueInt64() + 1)
+	}
+
+	var priorMetadata map[string]attr.Value
+	if !prior.metadata.IsNull() && !prior.metadata.IsUnknown() {
+		priorMetadata = prior.metadata.Elements()
+	}
+
+	existingKeys := p.existingKeys(prior.result)
+
+	metadata := make(map[string]attr.Value, len(p.mapping))
+	for key, value := range p.mapping {
+		value, ok := value.(basetypes.StringValue)
+		if !ok || value.IsUnknown() {
+			metadata[key] = basetypes.NewObjectUnknown(assignmentMetadataAttrTypes)
+			continue
+		}
+
+		// Respelling a key or value under normalization does not change its assignment.
+		if existing, ok := existingKeys[key]; ok && p.same(prior.result[existing], value.ValueString()) {
+			if entry, ok := priorMetadata[existing]; ok {
+				metadata[key] = entry
+				continue
+			}
+		}
+
+		metadata[key] = basetypes.NewObjectValueMust(assignmentMetadataAttrTypes, map[string]attr.Value{
+			"assigned_at": now,
+			"generation":  generation,
+			"hash":        basetypes.NewStringValue(assignmentHash(key, value.ValueString())),
+		})
+	}
+
+	return basetypes.NewMapValueMust(metadataType, metadata), generation
+}
+
+// valuesPending returns true when a value in the mapping will not be known until apply.
+func (p pairing) valuesPending() bool {
+	for _, value := range p.mapping {
+		if value.IsUnknown() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inverse returns the mapping of each value to the first of its keys in sorted order along with the mapping of
+// each value to all of its keys in sorted order. It assumes every value in the mapping is known.
+func (p pairing) inverse() (basetypes.MapValue, basetypes.MapValue) {
+	keys := make([]string, 0, len(p.mapping))
+	for key := range p.mapping {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	inverse := make(map[string]attr.Value, len(keys))
+	groups := make(map[string][]attr.Value, len(keys))
+
+	for _, key := range keys {
+		value, ok := p.mapping[key].(basetypes.StringValue)
+		if !ok {
+			continue
+		}
+
+		if _, ok := inverse[value.ValueString()]; !ok {
+			inverse[value.ValueString()] = basetypes.NewStringValue(key)
+		}
+
+		groups[value.ValueString()] = append(groups[value.ValueString()], basetypes.NewStringValue(key))
+	}
+
+	grouped := make(map[string]attr.Value, len(groups))
+	for value, keys := range groups {
+		grouped[value] = basetypes.NewListValueMust(types.StringType, keys)
+	}
+
+	return basetypes.NewMapValueMust(types.StringType, inverse), basetypes.NewMapValueMust(groupedType, grouped)
+}
+
+// ordered returns the mapping as a list of key, value and index objects sorted by index. Indexes always run
+// from zero to one less than the size of the mapping and a key keeps its existing index for as long as it stays
+// in the mapping and that index is still in range.
+func (p pairing) ordered(existingIndexes map[string]int64) basetypes.ListValue {
+	keys := make([]string, 0, len(p.mapping))
+	for key := range p.mapping {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	// Keys keep their existing index for as long as it is within the list, the first of them in sorted order
+	// should several have the same one, and every other key takes the lowest index left in sorted order.
+	indexes := make(map[string]int, len(keys))
+	taken := make([]bool, len(keys))
+
+	for _, key := range keys {
+		if index, ok := existingIndexes[key]; ok && index >= 0 && index < int64(len(keys)) && !taken[index] {
+			indexes[key] = int(index)
+			taken[index] = true
+		}
+	}
+
+	next := 0
+	for _, key := range keys {
+		if _, ok := indexes[key]; ok {
+			continue
+		}
+
+		for taken[next] {
+			next += 1
+		}
+
+		indexes[key] = next
+		taken[next] = true
+	}
+
+	elements := make([]attr.Value, len(keys))
+	for key, i := range indexes {
+		elements[i] = basetypes.NewObjectValueMust(orderedAttrTypes, map[string]attr.Value{
+			"index": basetypes.NewInt64Value(int64(i)),
+			"key":   basetypes.NewStringValue(key),
+			"value": p.mapping[key],
+		})
+	}
+
+	return basetypes.NewListValueMust(types.ObjectType{AttrTypes: orderedAttrTypes}, elements)
+}
+
+// assignments returns the mapping as a set of key and value objects along with the number of elements that
+// cannot be determined until apply. Unlike result, this is never entirely unknown: an assignment kept from the
+// existing result is always known, an assignment that depends on how unknown keys or values turn out has an
+// unknown value and assignments that may go to keys that are not known yet are represented by wholly unknown
+// elements, of which there are enough to cover every key that could still be assigned a value.
+func (p pairing) assignments() (basetypes.SetValue, int) {
+	pending := p.keysUnknown > 0 || p.valuesUnknown > 0
+
+	// When something is unknown, new assignments may be handed out in a different order at apply. A known key
+	// is still guaranteed some value if there are enough known free values for it and every unknown key.
+	guaranteed := p.newKeys+p.keysUnknown <= p.valuesFree
+
+	keys := make([]string, 0, len(p.mapping))
+	for key := range p.mapping {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	elements := make([]attr.Value, 0, len(keys))
+	unknown := 0
+
+	for _, key := range keys {
+		value := p.mapping[key]
+
+		if pending && !p.retained[key] {
+			if !guaranteed {
+				continue
+			}
+
+			value = basetypes.NewStringUnknown()
+		}
+
+		if value.IsUnknown() {
+			unknown += 1
+		}
+
+		elements = append(elements, basetypes.NewObjectValueMust(assignmentAttrTypes, map[string]attr.Value{
+			"key":   basetypes.NewStringValue(key),
+			"value": value,
+		}))
+	}
+
+	for len(elements) < min(p.keys, p.values) {
+		elements = append(elements, basetypes.NewObjectUnknown(assignmentAttrTypes))
+		unknown += 1
+	}
+
+	return basetypes.NewSetValueMust(types.ObjectType{AttrTypes: assignmentAttrTypes}, elements), unknown
 }
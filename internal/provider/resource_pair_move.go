@@ -0,0 +1,88 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+)
+
+func (r *PairResource) MoveState(ctx context.Context) []resource.StateMover {
+	return []resource.StateMover{
+		{StateMover: movePairState("hashicorp/random", "random_shuffle", randomShuffleResult)},
+		{StateMover: movePairState("terraform.io/builtin/terraform", "terraform_data", terraformDataResult)},
+	}
+}
+
+// movePairState returns a state mover that seeds the result from the raw state of the named type of the given
+// provider, ignoring the hostname of registry providers, with everything else left for the next plan to pair
+// the configured keys and values against. Any other type is left to the other state movers.
+func movePairState(provider, typeName string, result func(state map[string]json.RawMessage) (map[string]string, error)) func(context.Context, resource.MoveStateRequest, *resource.MoveStateResponse) {
+	return func(ctx context.Context, req resource.MoveStateRequest, resp *resource.MoveStateResponse) {
+		if req.SourceTypeName != typeName || (req.SourceProviderAddress != provider && !strings.HasSuffix(req.SourceProviderAddress, "/"+provider)) {
+			return
+		}
+
+		var state map[string]json.RawMessage
+		if req.SourceRawState == nil || json.Unmarshal(req.SourceRawState.JSON, &state) != nil {
+			resp.Diagnostics.AddError(
+				"Unable to Move Resource State",
+				fmt.Sprintf("The state of the %s could not be read.", typeName),
+			)
+			return
+		}
+
+		moved, err := result(state)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Unable to Move Resource State",
+				fmt.Sprintf("The result cannot be taken from the state of the %s: %s.", typeName, err),
+			)
+			return
+		}
+
+		seedState(ctx, moved, &resp.TargetState, &resp.Diagnostics)
+
+		identify(ctx, resp.TargetState, resp.TargetIdentity, &resp.Diagnostics)
+	}
+}
+
+// randomShuffleResult returns the result of a random_shuffle, which assigns each of its positions as a key, from
+// "0" on, the element at that position, just like indexing it does.
+func randomShuffleResult(state map[string]json.RawMessage) (map[string]string, error) {
+	var shuffled []string
+	if err := json.Unmarshal(state["result"], &shuffled); err != nil {
+		return nil, fmt.Errorf("its result is not a list of strings")
+	}
+
+	result := make(map[string]string, len(shuffled))
+	for i, value := range shuffled {
+		result[strconv.Itoa(i)] = value
+	}
+
+	return result, nil
+}
+
+// terraformDataResult returns the map of strings held by the output of a terraform_data.
+func terraformDataResult(state map[string]json.RawMessage) (map[string]string, error) {
+	// Dynamic values are stored along with their type.
+	var output struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(state["output"], &output); err != nil || output.Value == nil {
+		return nil, fmt.Errorf("its output is not set")
+	}
+
+	var result map[string]string
+	if err := json.Unmarshal(output.Value, &result); err != nil || result == nil {
+		return nil, fmt.Errorf("its output is not a map of strings")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,177 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestResourcePairMoveState(t *testing.T) {
+	server, err := providerserver.NewProtocol6WithError(New("test")())()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	schema := testSchema(t, NewPairResource()).Schema
+
+	var tests = []struct {
+		name     string
+		provider string
+		typeName string
+		state    string
+		result   map[string]string
+		err      bool
+	}{
+		{
+			name:     "random_shuffle",
+			provider: "registry.terraform.io/hashicorp/random",
+			typeName: "random_shuffle",
+			state:    `{"id":"-","input":["x","y","z"],"keepers":null,"result":["z","x","y"],"result_count":null,"seed":null}`,
+			result:   map[string]string{"0": "z", "1": "x", "2": "y"},
+		},
+		{
+			name:     "terraform_data",
+			provider: "terraform.io/builtin/terraform",
+			typeName: "terraform_data",
+			state:    `{"id":"0d9b","input":{"value":{"a":"1","b":"2"},"type":["map","string"]},"output":{"value":{"a":"1","b":"2"},"type":["map","string"]},"triggers_replace":null}`,
+			result:   map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:     "terraform_data without a map",
+			provider: "terraform.io/builtin/terraform",
+			typeName: "terraform_data",
+			state:    `{"id":"0d9b","input":{"value":["a"],"type":["list","string"]},"output":{"value":["a"],"type":["list","string"]},"triggers_replace":null}`,
+			err:      true,
+		},
+		{
+			name:     "other type",
+			provider: "registry.terraform.io/hashicorp/random",
+			typeName: "random_integer",
+			state:    `{"id":"4","keepers":null,"max":9,"min":0,"result":4,"seed":null}`,
+			err:      true,
+		},
+		{
+			name:     "other provider",
+			provider: "registry.terraform.io/example/random",
+			typeName: "random_shuffle",
+			state:    `{"id":"-","result":["a"]}`,
+			err:      true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := server.MoveResourceState(context.Background(), &tfprotov6.MoveResourceStateRequest{
+				SourceProviderAddress: test.provider,
+				SourceTypeName:        test.typeName,
+				SourceState:           &tfprotov6.RawState{JSON: []byte(test.state)},
+				TargetTypeName:        "stablepairer_pair",
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if test.err {
+				if len(resp.Diagnostics) == 0 {
+					t.Errorf("Got no diagnostics, wanted an error")
+				}
+				return
+			}
+
+			for _, diagnostic := range resp.Diagnostics {
+				t.Fatalf("Unexpected diagnostic: %s: %s", diagnostic.Summary, diagnostic.Detail)
+			}
+
+			state, err := resp.TargetState.Unmarshal(schema.Type().TerraformType(context.Background()))
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			var attributes map[string]tftypes.Value
+			if err := state.As(&attributes); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			var result map[string]tftypes.Value
+			if err := attributes["result"].As(&result); err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+
+			if len(result) != len(test.result) {
+				t.Fatalf("Got result %v, wanted %v", result, test.result)
+			}
+
+			for key, value := range test.result {
+				var got string
+				if err := result[key].As(&got); err != nil || got != value {
+					t.Errorf("Got %v for %q, wanted %q", result[key], key, value)
+				}
+			}
+
+			var id string
+			if err := attributes["id"].As(&id); err != nil || id != resultHash(test.result) {
+				t.Errorf("Got id %v, wanted the hash of the result", attributes["id"])
+			}
+		})
+	}
+}
+
+func TestAccResourcePairMoveFromTerraformData(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "terraform_data" "test" {
+					input = { a = "3", b = "1" }
+				}
+				`,
+			},
+			{
+				Config: `
+				moved {
+					from = terraform_data.test
+					to   = stablepairer_pair.test
+				}
+
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b", "c"]
+					values        = ["1", "2", "3"]
+					keepers       = { epoch = "1" }
+					reassign_keys = { a = "1" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b", "c"]
+					values        = ["1", "2", "3"]
+					keepers       = { epoch = "1" }
+					reassign_keys = { a = "1" }
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
@@ -8,15 +8,26 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"sort"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	fwresource "github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/knownvalue"
 	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/statecheck"
+	"github.com/hashicorp/terraform-plugin-testing/tfjsonpath"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
 )
 
 func TestAccResourcePair(t *testing.T) {
@@ -45,6 +56,10 @@ func TestAccResourcePair(t *testing.T) {
 					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
 					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
 					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignments.#", "3"),
+					resource.TestCheckTypeSetElemNestedAttrs("stablepairer_pair.test", "assignments.*", map[string]string{"key": "a", "value": "1"}),
+					resource.TestCheckTypeSetElemNestedAttrs("stablepairer_pair.test", "assignments.*", map[string]string{"key": "b", "value": "2"}),
+					resource.TestCheckTypeSetElemNestedAttrs("stablepairer_pair.test", "assignments.*", map[string]string{"key": "c", "value": "3"}),
 				),
 			},
 			{
@@ -198,6 +213,1970 @@ func TestAccResourcePairEmptyStart(t *testing.T) {
 	})
 }
 
+func TestAccResourcePairUnknownKey(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b"]
+					values = ["1", "2", "3"]
+				}
+				`,
+			},
+			{
+				// The assignment time of a resource being created is unknown until apply.
+				Config: `
+				resource "stablepairer_pair" "other" {
+					keys   = ["z"]
+					values = ["1"]
+				}
+
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", replace(stablepairer_pair.other.assignment_metadata["z"].assigned_at, "/^.*$/", "-")]
+					values = ["1", "2", "3"]
+				}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectUnknownValue("stablepairer_pair.test", tfjsonpath.New("result")),
+						plancheck.ExpectKnownValue("stablepairer_pair.test", tfjsonpath.New("assignments"), knownvalue.SetPartial([]knownvalue.Check{
+							knownvalue.ObjectExact(map[string]knownvalue.Check{
+								"key":   knownvalue.StringExact("a"),
+								"value": knownvalue.StringExact("1"),
+							}),
+							knownvalue.ObjectExact(map[string]knownvalue.Check{
+								"key":   knownvalue.StringExact("b"),
+								"value": knownvalue.StringExact("2"),
+							}),
+						})),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.-", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignments.#", "3"),
+					resource.TestCheckTypeSetElemNestedAttrs("stablepairer_pair.test", "assignments.*", map[string]string{"key": "-", "value": "3"}),
+				),
+			},
+		},
+	})
+}
+
+func TestResourcePairModifyPlanDeferral(t *testing.T) {
+	var tests = []struct {
+		keys            []attr.Value
+		deferralAllowed bool
+		deferred        bool
+	}{
+		{
+			keys: []attr.Value{
+				types.StringValue("a"),
+				types.StringUnknown(),
+			},
+			deferralAllowed: true,
+			deferred:        true,
+		},
+		{
+			keys: []attr.Value{
+				types.StringValue("a"),
+				types.StringUnknown(),
+			},
+			deferralAllowed: false,
+			deferred:        false,
+		},
+		{
+			keys: []attr.Value{
+				types.StringValue("a"),
+				types.StringValue("b"),
+			},
+			deferralAllowed: true,
+			deferred:        false,
+		},
+	}
+
+	for _, test := range tests {
+		testname := fmt.Sprintf("%+v,%t", test.keys, test.deferralAllowed)
+
+		t.Run(testname, func(t *testing.T) {
+			ctx := context.Background()
+			r := NewPairResource().(*PairResource)
+
+			req := fwresource.ModifyPlanRequest{
+				ClientCapabilities: fwresource.ModifyPlanClientCapabilities{
+					DeferralAllowed: test.deferralAllowed,
+				},
+				Plan: testPlan(t, r, testPairModel(
+					test.keys,
+					[]attr.Value{
+						types.StringValue("1"),
+						types.StringValue("2"),
+						types.StringValue("3"),
+					},
+					types.MapUnknown(types.StringType),
+				)),
+				State: testState(t, r, nil),
+			}
+			resp := fwresource.ModifyPlanResponse{
+				Plan: req.Plan,
+			}
+
+			r.ModifyPlan(ctx, req, &resp)
+
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("Unexpected diagnostics: %+v", resp.Diagnostics)
+			}
+
+			if deferred := resp.Deferred != nil; deferred != test.deferred {
+				t.Errorf("Got deferred %t, wanted %t", deferred, test.deferred)
+			}
+		})
+	}
+}
+
+func TestResourcePairModifyPlanUnknownValuesPath(t *testing.T) {
+	values := []attr.Value{
+		types.StringValue("1"),
+		types.StringUnknown(),
+	}
+
+	var tests = []struct {
+		name  string
+		model func(model pairModel) pairModel
+		path  path.Path
+	}{
+		{
+			name:  "values",
+			model: func(model pairModel) pairModel { return model },
+			path:  path.Root("values"),
+		},
+		{
+			name: "value_tiers",
+			model: func(model pairModel) pairModel {
+				model.Values = types.SetNull(types.StringType)
+				model.ValueTiers = types.ListValueMust(types.SetType{ElemType: types.StringType}, []attr.Value{
+					types.SetValueMust(types.StringType, []attr.Value{types.StringValue("1"), types.StringUnknown()}),
+				})
+				return model
+			},
+			path: path.Root("value_tiers"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := NewPairResource().(*PairResource)
+
+			req := fwresource.ModifyPlanRequest{
+				Plan: testPlan(t, r, test.model(testPairModel(
+					[]attr.Value{
+						types.StringValue("a"),
+						types.StringValue("b"),
+					},
+					values,
+					types.MapUnknown(types.StringType),
+				))),
+				State: testState(t, r, nil),
+			}
+			resp := fwresource.ModifyPlanResponse{
+				Plan: req.Plan,
+			}
+
+			r.ModifyPlan(ctx, req, &resp)
+
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("Unexpected diagnostics: %+v", resp.Diagnostics)
+			}
+
+			found := false
+			for _, diagnostic := range resp.Diagnostics.Warnings() {
+				if withPath, ok := diagnostic.(interface{ Path() path.Path }); ok && diagnostic.Summary() == "Assignments Depend On Unknown Values" {
+					found = true
+
+					if !withPath.Path().Equal(test.path) {
+						t.Errorf("Got warning at %s, wanted it at %s", withPath.Path(), test.path)
+					}
+				}
+			}
+
+			if !found {
+				t.Errorf("Got diagnostics %+v, wanted a warning about unknown values", resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestResourcePairModifyPlanInitialResult(t *testing.T) {
+	ctx := context.Background()
+	r := NewPairResource().(*PairResource)
+
+	model := testPairModel(
+		[]attr.Value{
+			types.StringValue("a"),
+			types.StringValue("b"),
+			types.StringValue("c"),
+			types.StringValue("d"),
+		},
+		[]attr.Value{
+			types.StringValue("1"),
+			types.StringValue("2"),
+			types.StringValue("3"),
+			types.StringValue("4"),
+		},
+		types.MapUnknown(types.StringType),
+	)
+	model.InitialResult = types.MapValueMust(types.StringType, map[string]attr.Value{
+		"a": types.StringValue("3"),
+		"b": types.StringValue("3"),
+		"c": types.StringValue("9"),
+		"x": types.StringValue("1"),
+	})
+
+	req := fwresource.ModifyPlanRequest{
+		Plan:  testPlan(t, r, model),
+		State: testState(t, r, nil),
+	}
+	resp := fwresource.ModifyPlanResponse{
+		Plan: req.Plan,
+	}
+
+	r.ModifyPlan(ctx, req, &resp)
+
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected diagnostics: %+v", resp.Diagnostics)
+	}
+
+	var warningPaths []string
+	for _, warning := range resp.Diagnostics.Warnings() {
+		if withPath, ok := warning.(diag.DiagnosticWithPath); ok && warning.Summary() == "Assignment Not Adopted" {
+			warningPaths = append(warningPaths, withPath.Path().String())
+		}
+	}
+
+	endWarningPaths := []string{`initial_result["b"]`, `initial_result["c"]`, `initial_result["x"]`}
+	if !reflect.DeepEqual(warningPaths, endWarningPaths) {
+		t.Errorf("Got warnings at %v, wanted %v", warningPaths, endWarningPaths)
+	}
+
+	var planned pairModel
+	if diags := resp.Plan.Get(ctx, &planned); diags.HasError() {
+		t.Fatalf("Unexpected plan diagnostics: %+v", diags)
+	}
+
+	endResult := types.MapValueMust(types.StringType, map[string]attr.Value{
+		"a": types.StringValue("3"),
+		"b": types.StringValue("1"),
+		"c": types.StringValue("2"),
+		"d": types.StringValue("4"),
+	})
+
+	if !planned.Result.Equal(endResult) {
+		t.Errorf("Got %+v, wanted %+v", planned.Result, endResult)
+	}
+}
+
+func TestResourcePairValidateConfigPaths(t *testing.T) {
+	strings := func(elements ...string) []attr.Value {
+		values := make([]attr.Value, 0, len(elements))
+		for _, element := range elements {
+			values = append(values, types.StringValue(element))
+		}
+
+		return values
+	}
+
+	var tests = []struct {
+		name      string
+		model     func(model *pairModel)
+		errorPath path.Path
+	}{
+		{
+			name:  "valid",
+			model: func(model *pairModel) { model.KeyPattern = types.StringValue("^[a-z]$") },
+		},
+		{
+			name:      "key pattern",
+			model:     func(model *pairModel) { model.KeyPattern = types.StringValue("^[ab]$") },
+			errorPath: path.Root("keys").AtSetValue(types.StringValue("c")),
+		},
+		{
+			name:      "value pattern",
+			model:     func(model *pairModel) { model.ValuePattern = types.StringValue("^[12]$") },
+			errorPath: path.Root("values").AtSetValue(types.StringValue("3")),
+		},
+		{
+			name:      "invalid pattern",
+			model:     func(model *pairModel) { model.ValuePattern = types.StringValue("(") },
+			errorPath: path.Root("value_pattern"),
+		},
+		{
+			name: "value objects pattern",
+			model: func(model *pairModel) {
+				model.Values = types.SetNull(types.StringType)
+				model.ValueObjects = types.MapValueMust(resultObjectType, map[string]attr.Value{
+					"1": types.MapValueMust(types.StringType, map[string]attr.Value{}),
+					"x": types.MapValueMust(types.StringType, map[string]attr.Value{}),
+				})
+				model.ValuePattern = types.StringValue("^[0-9]$")
+			},
+			errorPath: path.Root("value_objects").AtMapKey("x"),
+		},
+		{
+			name:      "locked key",
+			model:     func(model *pairModel) { model.LockedKeys = types.SetValueMust(types.StringType, strings("a", "z")) },
+			errorPath: path.Root("locked_keys").AtSetValue(types.StringValue("z")),
+		},
+		{
+			name:      "approved move",
+			model:     func(model *pairModel) { model.ApprovedMoves = types.SetValueMust(types.StringType, strings("z")) },
+			errorPath: path.Root("approved_moves").AtSetValue(types.StringValue("z")),
+		},
+		{
+			name: "reassigned key",
+			model: func(model *pairModel) {
+				model.ReassignKeys = types.MapValueMust(types.StringType, map[string]attr.Value{"z": types.StringValue("1")})
+			},
+			errorPath: path.Root("reassign_keys").AtMapKey("z"),
+		},
+		{
+			name: "locked key with unknown keys",
+			model: func(model *pairModel) {
+				model.Keys = types.SetValueMust(types.StringType, append(strings("a"), types.StringUnknown()))
+				model.LockedKeys = types.SetValueMust(types.StringType, strings("z"))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := NewPairResource().(*PairResource)
+
+			model := testPairModel(strings("a", "b", "c"), strings("1", "2", "3"), types.MapUnknown(types.StringType))
+			test.model(&model)
+
+			plan := testPlan(t, r, model)
+			req := fwresource.ValidateConfigRequest{
+				Config: tfsdk.Config{Raw: plan.Raw, Schema: plan.Schema},
+			}
+			resp := fwresource.ValidateConfigResponse{}
+
+			r.ValidateConfig(ctx, req, &resp)
+
+			// Like the framework, every config validator gets a response of its own.
+			for _, validator := range r.ConfigValidators(ctx) {
+				validatorResp := fwresource.ValidateConfigResponse{}
+				validator.ValidateResource(ctx, req, &validatorResp)
+				resp.Diagnostics.Append(validatorResp.Diagnostics...)
+			}
+
+			if len(test.errorPath.Steps()) == 0 {
+				if resp.Diagnostics.HasError() {
+					t.Fatalf("Got errors %v", resp.Diagnostics)
+				}
+				return
+			}
+
+			if len(resp.Diagnostics.Errors()) != 1 {
+				t.Fatalf("Got errors %v, wanted one at %s", resp.Diagnostics, test.errorPath)
+			}
+
+			if withPath, ok := resp.Diagnostics.Errors()[0].(diag.DiagnosticWithPath); !ok || !withPath.Path().Equal(test.errorPath) {
+				t.Errorf("Got error %v, wanted one at %s", resp.Diagnostics.Errors()[0], test.errorPath)
+			}
+		})
+	}
+}
+
+func TestResourcePairCreatePlannedResult(t *testing.T) {
+	var tests = []struct {
+		plannedResult types.Map
+		endResult     types.Map
+		error         bool
+	}{
+		// planned result matches recomputation
+		{
+			plannedResult: types.MapValueMust(types.StringType, map[string]attr.Value{
+				"a": types.StringValue("1"),
+				"b": types.StringValue("2"),
+			}),
+			endResult: types.MapValueMust(types.StringType, map[string]attr.Value{
+				"a": types.StringValue("1"),
+				"b": types.StringValue("2"),
+			}),
+		},
+		// unknown entries are resolved around the known ones, as planned
+		{
+			plannedResult: types.MapValueMust(types.StringType, map[string]attr.Value{
+				"a": types.StringValue("2"),
+				"b": types.StringUnknown(),
+			}),
+			endResult: types.MapValueMust(types.StringType, map[string]attr.Value{
+				"a": types.StringValue("2"),
+				"b": types.StringValue("1"),
+			}),
+		},
+		// entirely unknown result is recomputed
+		{
+			plannedResult: types.MapUnknown(types.StringType),
+			endResult: types.MapValueMust(types.StringType, map[string]attr.Value{
+				"a": types.StringValue("1"),
+				"b": types.StringValue("2"),
+			}),
+		},
+		// planned value that no longer exists
+		{
+			plannedResult: types.MapValueMust(types.StringType, map[string]attr.Value{
+				"a": types.StringValue("1"),
+				"b": types.StringValue("3"),
+			}),
+			error: true,
+		},
+	}
+
+	for _, test := range tests {
+		testname := fmt.Sprintf("%+v", test.plannedResult)
+
+		t.Run(testname, func(t *testing.T) {
+			ctx := context.Background()
+			r := NewPairResource().(*PairResource)
+
+			req := fwresource.CreateRequest{
+				Plan: testPlan(t, r, testPairModel(
+					[]attr.Value{
+						types.StringValue("a"),
+						types.StringValue("b"),
+					},
+					[]attr.Value{
+						types.StringValue("1"),
+						types.StringValue("2"),
+					},
+					test.plannedResult,
+				)),
+			}
+			resp := fwresource.CreateResponse{
+				State: testState(t, r, nil),
+			}
+
+			r.Create(ctx, req, &resp)
+
+			if resp.Diagnostics.HasError() != test.error {
+				t.Fatalf("Got diagnostics %+v, wanted error %t", resp.Diagnostics, test.error)
+			}
+
+			if test.error {
+				return
+			}
+
+			if resp.Diagnostics.WarningsCount() > 0 {
+				t.Errorf("Got diagnostics %+v, wanted no warnings", resp.Diagnostics)
+			}
+
+			var model pairModel
+			if diags := resp.State.Get(ctx, &model); diags.HasError() {
+				t.Fatalf("Unexpected state diagnostics: %+v", diags)
+			}
+
+			if !model.Result.Equal(test.endResult) {
+				t.Errorf("Got %+v, wanted %+v", model.Result, test.endResult)
+			}
+		})
+	}
+}
+
+func TestAccResourcePairNormalization(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["10.000.0.1", "10.0.0.1", "10.0.0.2"]
+					normalization = "ip"
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Duplicate Value After Normalization`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["10.0.0.1", "10.0.0.2", "10.0.0.3"]
+					normalization = "ip"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "10.0.0.1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "10.0.0.2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["10.000.0.1", "10.0.0.3", "10.0.000.2"]
+					normalization = "ip"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "10.000.0.1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "10.0.000.2"),
+					// Respelling a value is not a change of assignment.
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignment_metadata.a.hash", assignmentHash("a", "10.0.0.1")),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b", "c"]
+					values        = ["10.0.0.1", "10.0.0.3", "10.0.0.2"]
+					normalization = "ip"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "10.0.0.1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "10.0.0.2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "10.0.0.3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "added_keys.#", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "removed_keys.#", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "reassigned.%", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "freed_values.#", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "churn_ratio", "0.3333333333333333"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairValueObjects(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["1", "2"]
+					value_objects = {}
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Invalid Attribute Combination`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys = ["a", "b"]
+					value_objects = {
+						"host-1" = { ip = "10.0.0.1", zone = "a" }
+						"host-2" = { ip = "10.0.0.2", zone = "b", port = 443 }
+						"host-3" = { ip = "10.0.0.3", zone = "a" }
+					}
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "host-1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "host-2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_objects.%", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_objects.a.ip", "10.0.0.1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_objects.b.ip", "10.0.0.2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_objects.b.port", "443"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys = ["a", "b"]
+					value_objects = {
+						"host-1" = { ip = "10.0.1.1", zone = "a" }
+						"host-2" = { ip = "10.0.0.2", zone = "b", port = 443 }
+						"host-3" = { ip = "10.0.0.3", zone = "a" }
+					}
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "host-1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "host-2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_objects.a.ip", "10.0.1.1"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairKeyObjects(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					key_objects = [
+						{ region = "us", name = "web" },
+						{ region = "us", name = "web", size = "large" },
+					]
+					key_attributes = ["region", "name"]
+					values         = ["1", "2"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Duplicate Key`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					key_objects = [
+						{ region = "us", name = "web" },
+						{ region = "eu" },
+					]
+					key_attributes = ["region", "name"]
+					values         = ["1", "2"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Missing Key Attribute`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					key_objects = [
+						{ region = "us", name = "web", size = "large" },
+						{ region = "eu", name = "web", size = "small" },
+					]
+					key_attributes = ["region", "name"]
+					values         = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.eu/web", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.us/web", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_key_objects.%", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_key_objects.us/web.size", "large"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_key_objects.eu/web.size", "small"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					key_objects = [
+						{ region = "us", name = "web", size = "large" },
+						{ region = "eu", name = "web", size = "medium" },
+						{ region = "ap", name = "web", size = "small" },
+					]
+					key_attributes = ["region", "name"]
+					key_separator  = ":"
+					values         = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.ap:web", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.eu:web", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.us:web", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result_key_objects.eu:web.size", "medium"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairViews(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "inverse.%", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "inverse.2", "b"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "grouped.%", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "grouped.3.#", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "grouped.3.0", "c"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.#", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.0.key", "a"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.0.index", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.2.key", "c"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["0", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "inverse.1", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.#", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.0.key", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.0.value", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.1.key", "b"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "ordered.2.key", "c"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairChangeSummary(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "added_keys.#", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "removed_keys.#", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "reassigned.%", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "freed_values.#", "0"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "churn_ratio", "1"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "d"]
+					values = ["1", "4", "3"]
+				}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("stablepairer_pair.test", tfjsonpath.New("reassigned"), knownvalue.MapExact(map[string]knownvalue.Check{
+							"b": knownvalue.ObjectExact(map[string]knownvalue.Check{
+								"from": knownvalue.StringExact("2"),
+								"to":   knownvalue.StringExact("3"),
+							}),
+						})),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "added_keys.#", "1"),
+					resource.TestCheckTypeSetElemAttr("stablepairer_pair.test", "added_keys.*", "d"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "removed_keys.#", "1"),
+					resource.TestCheckTypeSetElemAttr("stablepairer_pair.test", "removed_keys.*", "c"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "reassigned.%", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "reassigned.b.from", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "reassigned.b.to", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "freed_values.#", "1"),
+					resource.TestCheckTypeSetElemAttr("stablepairer_pair.test", "freed_values.*", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "churn_ratio", "0.75"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "d"]
+					values = ["1", "4", "3"]
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairAssignmentMetadata(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignment_metadata.%", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignment_metadata.a.generation", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignment_metadata.a.hash", assignmentHash("a", "1")),
+					resource.TestMatchResourceAttr("stablepairer_pair.test", "assignment_metadata.a.assigned_at", regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T`)),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				PlanOnly: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b"]
+					values = ["1", "3"]
+				}
+				`,
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("stablepairer_pair.test", tfjsonpath.New("generation"), knownvalue.Int64Exact(2)),
+						plancheck.ExpectKnownValue("stablepairer_pair.test", tfjsonpath.New("assignment_metadata").AtMapKey("b").AtMapKey("hash"), knownvalue.StringExact(assignmentHash("b", "3"))),
+						plancheck.ExpectUnknownValue("stablepairer_pair.test", tfjsonpath.New("assignment_metadata").AtMapKey("b").AtMapKey("assigned_at")),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignment_metadata.a.generation", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignment_metadata.a.hash", assignmentHash("a", "1")),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignment_metadata.b.generation", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "assignment_metadata.b.hash", assignmentHash("b", "3")),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairRollback(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys         = ["a", "b"]
+					values       = ["1", "2", "3"]
+					history_size = 2
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "history.#", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "history.0.generation", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "history.0.result.a", "1"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys         = ["a", "b"]
+					values       = ["2", "3"]
+					history_size = 2
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "history.#", "2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                   = ["a", "b"]
+					values                 = ["1", "2", "3"]
+					history_size           = 2
+					rollback_to_generation = 3
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Unknown Generation`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                   = ["a", "b", "c"]
+					values                 = ["1", "2", "3"]
+					history_size           = 2
+					rollback_to_generation = 1
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "history.#", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "history.0.generation", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "history.1.generation", "3"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                   = ["a", "b", "c"]
+					values                 = ["1", "2", "3"]
+					history_size           = 2
+					rollback_to_generation = 1
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairChangePolicy(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b", "c"]
+					values        = ["1", "2", "3"]
+					change_policy = "strict"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "3"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b", "c"]
+					values        = ["1", "3", "4"]
+					change_policy = "strict"
+				}
+				`,
+				ExpectError: regexp.MustCompile(`(?s)Assignment Change Not Allowed.*key "b" cannot go from "2" to "4"`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys           = ["a", "b", "c"]
+					values         = ["2", "3", "4"]
+					change_policy  = "approved_only"
+					approved_moves = ["b"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`(?s)Assignment Change Not Approved.*key "a"`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys        = ["a", "b", "c"]
+					values      = ["1", "3", "4"]
+					locked_keys = ["b"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Locked Key Reassigned`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys           = ["a", "b"]
+					values         = ["1", "3", "4"]
+					change_policy  = "approved_only"
+					approved_moves = ["b"]
+					locked_keys    = ["a"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "3"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairChangePolicyNormalization(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["host-a", "host-b"]
+					values        = ["10.000.0.1", "10.0.0.2"]
+					normalization = "auto"
+					change_policy = "strict"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.host-a", "10.0.0.2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.host-b", "10.000.0.1"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["Host-A", "host-b"]
+					values        = ["10.0.0.1", "10.0.0.2"]
+					normalization = "auto"
+					change_policy = "strict"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.Host-A", "10.0.0.2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.host-b", "10.0.0.1"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["host-a", "host-b"]
+					values        = ["10.0.0.1", "10.0.0.3"]
+					normalization = "auto"
+					locked_keys   = ["host-a"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`(?s)Locked Key Reassigned.*key "host-a"`),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairReassignKeys(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["1", "2", "3"]
+					change_policy = "strict"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["1", "2", "3"]
+					change_policy = "strict"
+					reassign_keys = { a = "1" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["1", "2", "3"]
+					change_policy = "strict"
+					reassign_keys = { a = "1" }
+				}
+				`,
+				PlanOnly: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["2", "3"]
+					reassign_keys = { a = "2" }
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Unable to Reassign Key`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["1", "2", "3"]
+					reassign_keys = { a = "2" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairReassignKeysLeastUsed(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys               = ["a", "c", "d", "e"]
+					values             = ["1", "2", "3"]
+					max_keys_per_value = 2
+					initial_result     = { a = "1", c = "2", d = "2", e = "3" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.e", "3"),
+				),
+			},
+			{
+				// The value given up is the least used one, yet the key is not put back on it.
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys               = ["a", "c", "d", "e"]
+					values             = ["1", "2", "3"]
+					max_keys_per_value = 2
+					initial_result     = { a = "1", c = "2", d = "2", e = "3" }
+					reassign_keys      = { a = "1" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.d", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.e", "3"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairKeepers(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys    = ["a", "b"]
+					values  = ["1", "2", "3"]
+					keepers = { epoch = "1" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys    = ["a", "b"]
+					values  = ["2", "3", "4"]
+					keepers = { epoch = "1" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys    = ["a", "b"]
+					values  = ["2", "3", "4"]
+					keepers = { epoch = "2" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "3"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys    = ["a", "b"]
+					values  = ["2", "3", "4"]
+					keepers = { epoch = "2" }
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairRebalance(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys               = ["a", "b", "c", "d", "e", "f"]
+					values             = ["1", "2"]
+					max_keys_per_value = 3
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.e", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.f", "2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                = ["a", "b", "c", "d", "e", "f"]
+					values              = ["1", "2", "3", "4"]
+					max_keys_per_value  = 3
+					rebalance_tolerance = 1
+					rebalance_max_moves = 1
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.e", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.f", "2"),
+				),
+				// The remaining move is left for the next apply.
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                = ["a", "b", "c", "d", "e", "f"]
+					values              = ["1", "2", "3", "4"]
+					max_keys_per_value  = 3
+					rebalance_tolerance = 1
+					rebalance_max_moves = 1
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.e", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.f", "4"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                = ["a", "b", "c", "d", "e", "f"]
+					values              = ["1", "2", "3", "4"]
+					max_keys_per_value  = 3
+					rebalance_tolerance = 1
+					rebalance_max_moves = 1
+				}
+				`,
+				PlanOnly: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys               = ["a", "b", "c", "d", "e", "f", "g", "h"]
+					values             = ["1", "2", "3", "4"]
+					max_keys_per_value = 3
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.g", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.h", "4"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys               = ["a", "b", "c", "d", "e", "f", "g", "h"]
+					values             = ["1", "2", "3", "4", "5", "6"]
+					max_keys_per_value = 3
+					rebalance_trigger  = "1"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "grouped.5.#", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "grouped.6.#", "1"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys               = ["a", "b", "c", "d", "e", "f", "g", "h"]
+					values             = ["1", "2", "3", "4", "5", "6"]
+					max_keys_per_value = 3
+					rebalance_trigger  = "1"
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairValueTiers(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys        = ["a", "b", "c"]
+					value_tiers = [["r1", "r2"], ["o1", "o2"]]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "r1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "r2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "o1"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys        = ["a", "b", "c", "d"]
+					value_tiers = [["r1", "r2", "r3"], ["o1", "o2"]]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "o1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.d", "r3"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                     = ["a", "b", "c", "d", "e"]
+					value_tiers              = [["r1", "r2", "r3"], ["o1", "o2"]]
+					migrate_to_higher_tiers  = true
+					tier_migration_max_moves = 1
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "o1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.e", "o2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                     = ["a", "b", "c", "d", "e"]
+					value_tiers              = [["r1", "r2", "r3", "r4", "r5"], ["o1", "o2"]]
+					migrate_to_higher_tiers  = true
+					tier_migration_max_moves = 1
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "o1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.e", "r4"),
+				),
+				// The remaining move is left for the next apply.
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                     = ["a", "b", "c", "d", "e"]
+					value_tiers              = [["r1", "r2", "r3", "r4", "r5"], ["o1", "o2"]]
+					migrate_to_higher_tiers  = true
+					tier_migration_max_moves = 1
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "r5"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.e", "r4"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys        = ["a", "b", "c", "d", "e"]
+					value_tiers = [["r1", "r2", "r3", "r4", "r5"], ["r1", "o2"]]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Duplicate Value`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                     = ["a", "b", "c", "d", "e"]
+					value_tiers              = [["r1", "r2", "r3", "r4", "r5"], ["o1", "o2"]]
+					migrate_to_higher_tiers  = true
+					tier_migration_max_moves = 1
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairLocality(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                = ["a", "b"]
+					locality_attributes = ["zone", "subnet"]
+					value_objects = {
+						"host-1" = { zone = "us-east-1a", subnet = "subnet-1" }
+						"host-2" = { zone = "us-east-1b", subnet = "subnet-3" }
+						"host-3" = { zone = "us-east-1b", subnet = "subnet-4" }
+						"host-4" = { zone = "us-east-1a", subnet = "subnet-2" }
+						"host-5" = { zone = "us-east-1a", subnet = "subnet-1" }
+					}
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "host-1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "host-2"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                = ["a", "b"]
+					locality_attributes = ["zone", "subnet"]
+					value_objects = {
+						"host-3" = { zone = "us-east-1b", subnet = "subnet-4" }
+						"host-4" = { zone = "us-east-1a", subnet = "subnet-2" }
+						"host-5" = { zone = "us-east-1a", subnet = "subnet-1" }
+					}
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "host-5"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "host-3"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys                = ["a", "b"]
+					locality_attributes = ["zone", "subnet"]
+					value_objects = {
+						"host-3" = { zone = "us-east-1b", subnet = "subnet-4" }
+						"host-4" = { zone = "us-east-1a", subnet = "subnet-2" }
+						"host-5" = { zone = "us-east-1a", subnet = "subnet-1" }
+					}
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairOverflow(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "unassigned_keys.#", "1"),
+					resource.TestCheckTypeSetElemAttr("stablepairer_pair.test", "unassigned_keys.*", "c"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys     = ["a", "b", "c"]
+					values   = ["1", "2"]
+					overflow = "error"
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Key Left Unassigned`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys     = ["a", "b", "c"]
+					values   = ["1", "2"]
+					overflow = "share"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "unassigned_keys.#", "0"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys              = ["a", "b", "c"]
+					values            = ["1", "2"]
+					overflow          = "generate"
+					overflow_template = "1"
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Invalid Overflow Template`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys              = ["a", "b", "c", "d"]
+					values            = ["1", "2", "spare-1"]
+					overflow          = "generate"
+					overflow_template = "spare-%d"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "spare-1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.d", "spare-2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "unassigned_keys.#", "0"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys              = ["a", "b", "c", "d"]
+					values            = ["1", "2", "spare-1"]
+					overflow          = "generate"
+					overflow_template = "spare-%d"
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairGenerators(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					key_generator = {
+						patterns = ["tenant-{a,b}-{1..2}"]
+					}
+					value_generator = {
+						ranges  = [{ from = 1, to = 2000, format = "host-%04d" }]
+						exclude = ["host-0002"]
+					}
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "4"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.tenant-a-1", "host-0001"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.tenant-a-2", "host-0003"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.tenant-b-1", "host-0004"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.tenant-b-2", "host-0005"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					key_generator = {
+						patterns = ["tenant-{a,b}-{1..2}"]
+					}
+					value_generator = {
+						ranges  = [{ from = 1, to = 2000, format = "host-%04d" }]
+						exclude = ["host-0001"]
+					}
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.tenant-a-1", "host-0002"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.tenant-a-2", "host-0003"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					key_generator = {
+						patterns = ["tenant-{a,b}-{1..2"]
+					}
+					values = ["1"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Invalid Pattern`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					key_generator = {
+						patterns = ["tenant-{a,b}-{1..2}"]
+					}
+					value_generator = {
+						ranges  = [{ from = 1, to = 2000, format = "host-%04d" }]
+						exclude = ["host-0001"]
+					}
+				}
+				`,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairInitialResult(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys           = ["a", "b", "c"]
+					values         = ["1", "2", "3"]
+					initial_result = { a = "3", b = "2", x = "1" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "added_keys.#", "1"),
+					resource.TestCheckTypeSetElemAttr("stablepairer_pair.test", "added_keys.*", "c"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys           = ["a", "b", "c"]
+					values         = ["1", "2", "3"]
+					initial_result = { a = "3", b = "2", x = "1" }
+				}
+				`,
+				PlanOnly: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys           = ["a", "b", "c"]
+					values         = ["1", "2", "3"]
+					initial_result = { a = "1", b = "2", c = "3" }
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "1"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairIdentity(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_12_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestMatchResourceAttr("stablepairer_pair.test", "id", regexp.MustCompile(`^[0-9a-f]{64}$`)),
+				),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectIdentityValueMatchesState("stablepairer_pair.test", tfjsonpath.New("id")),
+				},
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+					name   = "blue"
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "id", "blue"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "1"),
+				),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectIdentity("stablepairer_pair.test", map[string]knownvalue.Check{
+						"id": knownvalue.StringExact("blue"),
+					}),
+				},
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+					name   = "blue"
+				}
+				`,
+				ResourceName:    "stablepairer_pair.test",
+				ImportState:     true,
+				ImportStateKind: resource.ImportBlockWithResourceIdentity,
+				// An identity carries no result, so the imported pair is paired from scratch.
+				ExpectNonEmptyPlan: true,
+				ImportPlanChecks: resource.ImportPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectKnownValue("stablepairer_pair.test", tfjsonpath.New("id"), knownvalue.StringExact("blue")),
+						plancheck.ExpectKnownValue("stablepairer_pair.test", tfjsonpath.New("result"), knownvalue.MapExact(map[string]knownvalue.Check{
+							"a": knownvalue.StringExact("1"),
+							"b": knownvalue.StringExact("2"),
+							"c": knownvalue.StringExact("3"),
+						})),
+					},
+				},
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c", "d"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestMatchResourceAttr("stablepairer_pair.test", "id", regexp.MustCompile(`^[0-9a-f]{64}$`)),
+				),
+				ConfigStateChecks: []statecheck.StateCheck{
+					statecheck.ExpectIdentityValueMatchesState("stablepairer_pair.test", tfjsonpath.New("id")),
+				},
+			},
+		},
+	})
+}
+
+func TestAccResourcePairImport(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				ResourceName:       "stablepairer_pair.test",
+				ImportState:        true,
+				ImportStateId:      `{"a":"3","b":"2","x":"1"}`,
+				ImportStatePersist: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "id", resultHash(map[string]string{"a": "3", "b": "2", "x": "1"})),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "added_keys.#", "1"),
+					resource.TestCheckTypeSetElemAttr("stablepairer_pair.test", "added_keys.*", "c"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "removed_keys.#", "1"),
+					resource.TestCheckTypeSetElemAttr("stablepairer_pair.test", "removed_keys.*", "x"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				PlanOnly: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				ResourceName:  "stablepairer_pair.test",
+				ImportState:   true,
+				ImportStateId: "a=3,a=2",
+				ExpectError:   regexp.MustCompile(`Invalid Import ID`),
+			},
+		},
+	})
+}
+
+func TestAccResourcePairImportTriggers(t *testing.T) {
+	// The keepers, reassign_keys and rebalance_trigger configured when importing have nothing to act on yet.
+	config := `
+	resource "stablepairer_pair" "test" {
+		keys              = ["a", "b", "c"]
+		values            = ["1", "2", "3"]
+		keepers           = { epoch = "1" }
+		reassign_keys     = { b = "1" }
+		rebalance_trigger = "1"
+	}
+	`
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config:             config,
+				ResourceName:       "stablepairer_pair.test",
+				ImportState:        true,
+				ImportStateId:      "a=3,b=2,c=1",
+				ImportStatePersist: true,
+			},
+			{
+				Config: config,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.a", "3"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.c", "1"),
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "generation", "1"),
+				),
+			},
+			{
+				Config:   config,
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func TestAccResourcePairValidation(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", ""]
+					values = ["1", "2"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Invalid Attribute Value Length`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys   = ["a", "b"]
+					values = ["1", null]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Null Set Value`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys        = ["a", "B"]
+					values      = ["1", "2"]
+					key_pattern = "^[a-z]+$"
+				}
+				`,
+				ExpectError: regexp.MustCompile(`(?s)Key Does Not Match Pattern.*"B" does not match`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["1", "2"]
+					value_pattern = "^[0-9"
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Invalid Value Pattern`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys        = ["a", "b"]
+					values      = ["1", "2"]
+					locked_keys = ["a", "c"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`(?s)Unconfigured Key.*"c" is not one of the configured keys`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["1", "2"]
+					reassign_keys = { c = "1" }
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Unconfigured Key`),
+			},
+			{
+				Config: `
+				resource "stablepairer_pair" "test" {
+					keys          = ["a", "b"]
+					values        = ["1", "2"]
+					key_pattern   = "^[a-z]+$"
+					value_pattern = "^[0-9]+$"
+					locked_keys   = ["a"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_pair.test", "result.%", "2"),
+				),
+			},
+		},
+	})
+}
+
 func TestInternalPairStable(t *testing.T) {
 	var tests = []struct {
 		keys, values   []basetypes.StringValue
@@ -253,7 +2232,255 @@ func TestInternalPairStable(t *testing.T) {
 				basetypes.NewStringValue("1"),
 				basetypes.NewStringValue("2"),
 				basetypes.NewStringValue("3"),
-				basetypes.NewStringValue("4"),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("3"),
+				"c": basetypes.NewStringValue("2"),
+			}),
+		},
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"c": "2",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"c": basetypes.NewStringValue("2"),
+			}),
+		},
+		// stable - addition
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringValue("d"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("3"),
+				"c": basetypes.NewStringValue("2"),
+				"d": basetypes.NewStringValue("4"),
+			}),
+		},
+		// stable - removal
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"b": basetypes.NewStringValue("3"),
+				"c": basetypes.NewStringValue("2"),
+			}),
+		},
+		// stable - addition & removal
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringValue("e"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"b": basetypes.NewStringValue("3"),
+				"c": basetypes.NewStringValue("2"),
+				"e": basetypes.NewStringValue("1"),
+			}),
+		},
+		// stable - addition & removal at max
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringValue("e"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+				"d": "4",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("3"),
+				"c": basetypes.NewStringValue("2"),
+				"e": basetypes.NewStringValue("4"),
+			}),
+		},
+		// stable - addition & removal over max
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringValue("e"),
+				basetypes.NewStringValue("f"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+				"d": "4",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("3"),
+				"c": basetypes.NewStringValue("2"),
+				"e": basetypes.NewStringValue("4"),
+			}),
+		},
+		// Keys and / or Values Known
+		///////////////////////////////////////////////////////////////////////////
+		// empty start
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringUnknown(),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringValue("d"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("2"),
+				"c": basetypes.NewStringValue("3"),
+				"d": basetypes.NewStringValue("4"),
+			}),
+		},
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringUnknown(),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("2"),
+				"c": basetypes.NewStringValue("4"),
+			}),
+		},
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringUnknown(),
+			},
+			startingResult: map[string]string{},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("2"),
+				"c": basetypes.NewStringUnknown(),
+			}),
+		},
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringUnknown(),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringUnknown(),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{},
+			endResult:      basetypes.NewMapUnknown(types.StringType),
+		},
+		// stable
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringUnknown(),
 			},
 			startingResult: map[string]string{
 				"a": "1",
@@ -269,7 +2496,7 @@ func TestInternalPairStable(t *testing.T) {
 		{
 			keys: []basetypes.StringValue{
 				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
+				basetypes.NewStringUnknown(),
 				basetypes.NewStringValue("c"),
 			},
 			values: []basetypes.StringValue{
@@ -291,7 +2518,7 @@ func TestInternalPairStable(t *testing.T) {
 				basetypes.NewStringValue("a"),
 				basetypes.NewStringValue("b"),
 				basetypes.NewStringValue("c"),
-				basetypes.NewStringValue("d"),
+				basetypes.NewStringUnknown(),
 			},
 			values: []basetypes.StringValue{
 				basetypes.NewStringValue("1"),
@@ -304,24 +2531,91 @@ func TestInternalPairStable(t *testing.T) {
 				"b": "3",
 				"c": "2",
 			},
+			endResult: basetypes.NewMapUnknown(types.StringType),
+		},
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringUnknown(),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+			},
 			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
 				"a": basetypes.NewStringValue("1"),
 				"b": basetypes.NewStringValue("3"),
 				"c": basetypes.NewStringValue("2"),
-				"d": basetypes.NewStringValue("4"),
 			}),
 		},
-		// stable - removal
 		{
 			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
 				basetypes.NewStringValue("b"),
 				basetypes.NewStringValue("c"),
+				basetypes.NewStringValue("d"),
 			},
 			values: []basetypes.StringValue{
 				basetypes.NewStringValue("1"),
 				basetypes.NewStringValue("2"),
 				basetypes.NewStringValue("3"),
-				basetypes.NewStringValue("4"),
+				basetypes.NewStringUnknown(),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("3"),
+				"c": basetypes.NewStringValue("2"),
+				"d": basetypes.NewStringUnknown(),
+			}),
+		},
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringUnknown(),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+			},
+			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue("1"),
+				"b": basetypes.NewStringValue("3"),
+				"c": basetypes.NewStringValue("2"),
+			}),
+		},
+		// stable - addition & removal
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringValue("e"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringUnknown(),
 			},
 			startingResult: map[string]string{
 				"a": "1",
@@ -331,334 +2625,820 @@ func TestInternalPairStable(t *testing.T) {
 			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
 				"b": basetypes.NewStringValue("3"),
 				"c": basetypes.NewStringValue("2"),
+				"e": basetypes.NewStringValue("1"),
 			}),
 		},
-		// stable - addition & removal
+	}
+
+	for _, test := range tests {
+		testname := fmt.Sprintf("%+v,%+v,%+v", test.keys, test.values, test.startingResult)
+
+		t.Run(testname, func(t *testing.T) {
+			actualResult := pairStable(test.startingResult, test.keys, test.values)
+
+			if !reflect.DeepEqual(test.endResult, actualResult) {
+				t.Errorf("Got %+v, wanted %+v", actualResult, test.endResult)
+			}
+		})
+	}
+}
+
+func TestInternalPairAssignments(t *testing.T) {
+	assignment := func(key string, value basetypes.StringValue) attr.Value {
+		return basetypes.NewObjectValueMust(assignmentAttrTypes, map[string]attr.Value{
+			"key":   basetypes.NewStringValue(key),
+			"value": value,
+		})
+	}
+
+	pending := basetypes.NewObjectUnknown(assignmentAttrTypes)
+
+	var tests = []struct {
+		keys, values   []basetypes.StringValue
+		startingResult map[string]string
+		endAssignments []attr.Value
+		endUnknown     int
+	}{
+		// all known
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+			},
+			startingResult: map[string]string{
+				"b": "2",
+			},
+			endAssignments: []attr.Value{
+				assignment("a", basetypes.NewStringValue("1")),
+				assignment("b", basetypes.NewStringValue("2")),
+			},
+		},
+		// unknown key with a free value keeps existing assignments known
+		{
+			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
+				basetypes.NewStringValue("b"),
+				basetypes.NewStringValue("c"),
+				basetypes.NewStringUnknown(),
+			},
+			values: []basetypes.StringValue{
+				basetypes.NewStringValue("1"),
+				basetypes.NewStringValue("2"),
+				basetypes.NewStringValue("3"),
+				basetypes.NewStringValue("4"),
+			},
+			startingResult: map[string]string{
+				"a": "1",
+				"b": "3",
+				"c": "2",
+			},
+			endAssignments: []attr.Value{
+				assignment("a", basetypes.NewStringValue("1")),
+				assignment("b", basetypes.NewStringValue("3")),
+				assignment("c", basetypes.NewStringValue("2")),
+				pending,
+			},
+			endUnknown: 1,
+		},
+		// new key guaranteed a value, but which one depends on the unknown key
 		{
 			keys: []basetypes.StringValue{
+				basetypes.NewStringValue("a"),
 				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-				basetypes.NewStringValue("e"),
+				basetypes.NewStringUnknown(),
 			},
 			values: []basetypes.StringValue{
 				basetypes.NewStringValue("1"),
 				basetypes.NewStringValue("2"),
 				basetypes.NewStringValue("3"),
-				basetypes.NewStringValue("4"),
 			},
 			startingResult: map[string]string{
 				"a": "1",
-				"b": "3",
-				"c": "2",
 			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"b": basetypes.NewStringValue("3"),
-				"c": basetypes.NewStringValue("2"),
-				"e": basetypes.NewStringValue("1"),
-			}),
+			endAssignments: []attr.Value{
+				assignment("a", basetypes.NewStringValue("1")),
+				assignment("b", basetypes.NewStringUnknown()),
+				pending,
+			},
+			endUnknown: 2,
 		},
-		// stable - addition & removal at max
+		// new key competing with an unknown key for the last value
 		{
 			keys: []basetypes.StringValue{
 				basetypes.NewStringValue("a"),
 				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-				basetypes.NewStringValue("e"),
+				basetypes.NewStringUnknown(),
 			},
 			values: []basetypes.StringValue{
 				basetypes.NewStringValue("1"),
 				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-				basetypes.NewStringValue("4"),
 			},
 			startingResult: map[string]string{
 				"a": "1",
-				"b": "3",
-				"c": "2",
-				"d": "4",
 			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("3"),
-				"c": basetypes.NewStringValue("2"),
-				"e": basetypes.NewStringValue("4"),
-			}),
+			endAssignments: []attr.Value{
+				assignment("a", basetypes.NewStringValue("1")),
+				pending,
+			},
+			endUnknown: 1,
 		},
-		// stable - addition & removal over max
+		// unknown value
 		{
 			keys: []basetypes.StringValue{
 				basetypes.NewStringValue("a"),
 				basetypes.NewStringValue("b"),
 				basetypes.NewStringValue("c"),
-				basetypes.NewStringValue("e"),
-				basetypes.NewStringValue("f"),
 			},
 			values: []basetypes.StringValue{
 				basetypes.NewStringValue("1"),
 				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-				basetypes.NewStringValue("4"),
+				basetypes.NewStringUnknown(),
 			},
 			startingResult: map[string]string{
-				"a": "1",
-				"b": "3",
 				"c": "2",
-				"d": "4",
 			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("3"),
-				"c": basetypes.NewStringValue("2"),
-				"e": basetypes.NewStringValue("4"),
-			}),
+			endAssignments: []attr.Value{
+				assignment("c", basetypes.NewStringValue("2")),
+				pending,
+				pending,
+			},
+			endUnknown: 2,
 		},
-		// Keys and / or Values Known
-		///////////////////////////////////////////////////////////////////////////
-		// empty start
+	}
+
+	for _, test := range tests {
+		testname := fmt.Sprintf("%+v,%+v,%+v", test.keys, test.values, test.startingResult)
+
+		t.Run(testname, func(t *testing.T) {
+			actualAssignments, actualUnknown := pair(test.startingResult, test.keys, test.values, pairOptions{}).assignments()
+			endAssignments := basetypes.NewSetValueMust(types.ObjectType{AttrTypes: assignmentAttrTypes}, test.endAssignments)
+
+			if !reflect.DeepEqual(endAssignments, actualAssignments) {
+				t.Errorf("Got %+v, wanted %+v", actualAssignments, endAssignments)
+			}
+
+			if actualUnknown != test.endUnknown {
+				t.Errorf("Got %d unknown, wanted %d", actualUnknown, test.endUnknown)
+			}
+		})
+	}
+}
+
+func TestInternalPairNormalized(t *testing.T) {
+	var tests = []struct {
+		normalization  string
+		keys, values   []basetypes.StringValue
+		startingResult map[string]string
+		endResult      basetypes.MapValue
+	}{
+		// values reformatted
 		{
+			normalization: normalizationIP,
 			keys: []basetypes.StringValue{
 				basetypes.NewStringValue("a"),
 				basetypes.NewStringValue("b"),
-				basetypes.NewStringUnknown(),
-				basetypes.NewStringValue("c"),
-				basetypes.NewStringValue("d"),
 			},
 			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-				basetypes.NewStringValue("4"),
+				basetypes.NewStringValue("10.000.0.1"),
+				basetypes.NewStringValue("2001:DB8::1"),
+				basetypes.NewStringValue("10.0.0.3"),
+			},
+			startingResult: map[string]string{
+				"a": "2001:db8:0::1",
+				"b": "10.0.0.1",
 			},
-			startingResult: map[string]string{},
 			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("2"),
-				"c": basetypes.NewStringValue("3"),
-				"d": basetypes.NewStringValue("4"),
+				"a": basetypes.NewStringValue("2001:DB8::1"),
+				"b": basetypes.NewStringValue("10.000.0.1"),
 			}),
 		},
+		// keys reformatted
 		{
+			normalization: normalizationHostname,
 			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
+				basetypes.NewStringValue("Host-A.example.com"),
+				basetypes.NewStringValue("host-b.example.com."),
 			},
 			values: []basetypes.StringValue{
 				basetypes.NewStringValue("1"),
 				basetypes.NewStringValue("2"),
-				basetypes.NewStringUnknown(),
-				basetypes.NewStringValue("4"),
 			},
-			startingResult: map[string]string{},
+			startingResult: map[string]string{
+				"host-a.example.com": "2",
+				"host-b.example.com": "1",
+			},
 			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("2"),
-				"c": basetypes.NewStringValue("4"),
+				"Host-A.example.com":  basetypes.NewStringValue("2"),
+				"host-b.example.com.": basetypes.NewStringValue("1"),
 			}),
 		},
+		// no normalization
 		{
+			normalization: normalizationNone,
 			keys: []basetypes.StringValue{
 				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
 			},
 			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringUnknown(),
+				basetypes.NewStringValue("10.000.0.1"),
+				basetypes.NewStringValue("10.0.0.2"),
+			},
+			startingResult: map[string]string{
+				"a": "10.0.0.1",
 			},
-			startingResult: map[string]string{},
 			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("2"),
-				"c": basetypes.NewStringUnknown(),
+				"a": basetypes.NewStringValue("10.000.0.1"),
+			}),
+		},
+	}
+
+	for _, test := range tests {
+		testname := fmt.Sprintf("%s,%+v,%+v,%+v", test.normalization, test.keys, test.values, test.startingResult)
+
+		t.Run(testname, func(t *testing.T) {
+			actualResult := pair(test.startingResult, test.keys, test.values, pairOptions{normalization: test.normalization}).result()
+
+			if !reflect.DeepEqual(test.endResult, actualResult) {
+				t.Errorf("Got %+v, wanted %+v", actualResult, test.endResult)
+			}
+		})
+	}
+}
+
+func TestInternalPairOrdered(t *testing.T) {
+	entry := func(index int64, key, value string) attr.Value {
+		return basetypes.NewObjectValueMust(orderedAttrTypes, map[string]attr.Value{
+			"index": basetypes.NewInt64Value(index),
+			"key":   basetypes.NewStringValue(key),
+			"value": basetypes.NewStringValue(value),
+		})
+	}
+
+	var tests = []struct {
+		name            string
+		mapping         map[string]string
+		existingIndexes map[string]int64
+		endOrdered      []attr.Value
+	}{
+		{
+			name:       "empty start",
+			mapping:    map[string]string{"b": "1", "a": "2", "c": "3"},
+			endOrdered: []attr.Value{entry(0, "a", "2"), entry(1, "b", "1"), entry(2, "c", "3")},
+		},
+		{
+			name:            "key added",
+			mapping:         map[string]string{"a": "2", "b": "1", "c": "3", "0": "4"},
+			existingIndexes: map[string]int64{"a": 0, "b": 1, "c": 2},
+			endOrdered:      []attr.Value{entry(0, "a", "2"), entry(1, "b", "1"), entry(2, "c", "3"), entry(3, "0", "4")},
+		},
+		{
+			name:            "key removed",
+			mapping:         map[string]string{"b": "1", "c": "3", "d": "4"},
+			existingIndexes: map[string]int64{"a": 0, "b": 1, "c": 2, "d": 3},
+			endOrdered:      []attr.Value{entry(0, "d", "4"), entry(1, "b", "1"), entry(2, "c", "3")},
+		},
+		{
+			name:            "index out of range",
+			mapping:         map[string]string{"a": "1", "b": "2", "c": "3"},
+			existingIndexes: map[string]int64{"a": 5, "b": 0, "c": 0},
+			endOrdered:      []attr.Value{entry(0, "b", "2"), entry(1, "a", "1"), entry(2, "c", "3")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := pairing{mapping: make(map[string]attr.Value, len(test.mapping))}
+			for key, value := range test.mapping {
+				p.mapping[key] = basetypes.NewStringValue(value)
+			}
+
+			endOrdered := basetypes.NewListValueMust(types.ObjectType{AttrTypes: orderedAttrTypes}, test.endOrdered)
+			if actualOrdered := p.ordered(test.existingIndexes); !endOrdered.Equal(actualOrdered) {
+				t.Errorf("Got %+v, wanted %+v", actualOrdered, endOrdered)
+			}
+		})
+	}
+}
+
+func TestInternalPairInverse(t *testing.T) {
+	p := pairing{mapping: map[string]attr.Value{
+		"b": basetypes.NewStringValue("1"),
+		"a": basetypes.NewStringValue("1"),
+		"c": basetypes.NewStringValue("2"),
+	}}
+
+	endInverse := basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+		"1": basetypes.NewStringValue("a"),
+		"2": basetypes.NewStringValue("c"),
+	})
+	endGrouped := basetypes.NewMapValueMust(groupedType, map[string]attr.Value{
+		"1": basetypes.NewListValueMust(types.StringType, []attr.Value{basetypes.NewStringValue("a"), basetypes.NewStringValue("b")}),
+		"2": basetypes.NewListValueMust(types.StringType, []attr.Value{basetypes.NewStringValue("c")}),
+	})
+
+	actualInverse, actualGrouped := p.inverse()
+
+	if !endInverse.Equal(actualInverse) {
+		t.Errorf("Got inverse %+v, wanted %+v", actualInverse, endInverse)
+	}
+
+	if !endGrouped.Equal(actualGrouped) {
+		t.Errorf("Got grouped %+v, wanted %+v", actualGrouped, endGrouped)
+	}
+}
+
+func TestInternalPairSummary(t *testing.T) {
+	p := pairing{mapping: map[string]attr.Value{
+		"a": basetypes.NewStringValue("1"),
+		"b": basetypes.NewStringValue("4"),
+		"d": basetypes.NewStringValue("3"),
+	}}
+
+	summary := p.summary(map[string]string{"a": "1", "b": "2", "c": "3"})
+
+	if expected := basetypes.NewSetValueMust(types.StringType, []attr.Value{basetypes.NewStringValue("d")}); !summary.addedKeys.Equal(expected) {
+		t.Errorf("Got added keys %+v, wanted %+v", summary.addedKeys, expected)
+	}
+
+	if expected := basetypes.NewSetValueMust(types.StringType, []attr.Value{basetypes.NewStringValue("c")}); !summary.removedKeys.Equal(expected) {
+		t.Errorf("Got removed keys %+v, wanted %+v", summary.removedKeys, expected)
+	}
+
+	if expected := basetypes.NewSetValueMust(types.StringType, []attr.Value{basetypes.NewStringValue("2")}); !summary.freedValues.Equal(expected) {
+		t.Errorf("Got freed values %+v, wanted %+v", summary.freedValues, expected)
+	}
+
+	if len(summary.reassigned.Elements()) != 1 {
+		t.Errorf("Got reassigned %+v, wanted only b", summary.reassigned)
+	}
+
+	if expected := basetypes.NewFloat64Value(0.75); !summary.churnRatio.Equal(expected) {
+		t.Errorf("Got churn ratio %+v, wanted %+v", summary.churnRatio, expected)
+	}
+
+	pending := pairing{mapping: map[string]attr.Value{
+		"a": basetypes.NewStringValue("1"),
+		"b": basetypes.NewStringUnknown(),
+	}}
+
+	summary = pending.summary(map[string]string{"a": "1", "b": "2"})
+
+	if !summary.addedKeys.Equal(basetypes.NewSetValueMust(types.StringType, nil)) {
+		t.Errorf("Got added keys %+v, wanted none", summary.addedKeys)
+	}
+
+	if !summary.reassigned.IsUnknown() || !summary.freedValues.IsUnknown() || !summary.churnRatio.IsUnknown() {
+		t.Errorf("Got %+v, wanted reassigned, freed values and churn ratio unknown", summary)
+	}
+}
+
+func TestInternalHistoryValue(t *testing.T) {
+	entry := func(generation int64, a string) attr.Value {
+		return basetypes.NewObjectValueMust(historyAttrTypes, map[string]attr.Value{
+			"generation": basetypes.NewInt64Value(generation),
+			"result": basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
+				"a": basetypes.NewStringValue(a),
 			}),
+		})
+	}
+
+	prior := priorPairing{history: []historyEntry{
+		{generation: 1, result: map[string]string{"a": "1"}},
+		{generation: 2, result: map[string]string{"a": "2"}},
+	}}
+
+	var tests = []struct {
+		name        string
+		prior       priorPairing
+		entry       *historyEntry
+		size        int64
+		endElements []attr.Value
+		endNull     bool
+	}{
+		{
+			name:        "appended",
+			prior:       prior,
+			entry:       &historyEntry{generation: 3, result: map[string]string{"a": "3"}},
+			size:        5,
+			endElements: []attr.Value{entry(1, "1"), entry(2, "2"), entry(3, "3")},
+		},
+		{
+			name:        "trimmed",
+			prior:       prior,
+			entry:       &historyEntry{generation: 3, result: map[string]string{"a": "3"}},
+			size:        2,
+			endElements: []attr.Value{entry(2, "2"), entry(3, "3")},
+		},
+		{
+			name:        "kept",
+			prior:       prior,
+			size:        1,
+			endElements: []attr.Value{entry(2, "2")},
+		},
+		{
+			name:        "empty",
+			prior:       prior,
+			entry:       &historyEntry{generation: 3, result: map[string]string{"a": "3"}},
+			size:        0,
+			endElements: []attr.Value{},
+		},
+		{
+			name:    "none",
+			size:    5,
+			endNull: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualHistory := test.prior.historyValue(test.entry, test.size)
+
+			endHistory := basetypes.NewListNull(types.ObjectType{AttrTypes: historyAttrTypes})
+			if !test.endNull {
+				endHistory = basetypes.NewListValueMust(types.ObjectType{AttrTypes: historyAttrTypes}, test.endElements)
+			}
+
+			if !endHistory.Equal(actualHistory) {
+				t.Errorf("Got %+v, wanted %+v", actualHistory, endHistory)
+			}
+		})
+	}
+}
+
+func TestInternalPairRebalance(t *testing.T) {
+	stringValues := func(values ...string) []basetypes.StringValue {
+		elements := make([]basetypes.StringValue, 0, len(values))
+		for _, value := range values {
+			elements = append(elements, basetypes.NewStringValue(value))
+		}
+
+		return elements
+	}
+
+	startingResult := map[string]string{"a": "1", "b": "1", "c": "1", "d": "2", "e": "2"}
+	keys := stringValues("a", "b", "c", "d", "e")
+	values := stringValues("1", "2", "3")
+	options := pairOptions{keysPerValue: 3}
+
+	var tests = []struct {
+		name      string
+		tolerance int
+		maxMoves  int
+		locked    map[string]bool
+		endResult map[string]string
+	}{
+		{
+			name:      "balanced",
+			tolerance: 1,
+			maxMoves:  -1,
+			endResult: map[string]string{"a": "1", "b": "1", "c": "3", "d": "2", "e": "2"},
+		},
+		{
+			name:      "tolerated",
+			tolerance: 3,
+			maxMoves:  -1,
+			endResult: startingResult,
+		},
+		{
+			name:      "bounded",
+			tolerance: 0,
+			maxMoves:  1,
+			endResult: map[string]string{"a": "1", "b": "1", "c": "3", "d": "2", "e": "2"},
+		},
+		{
+			name:      "locked",
+			tolerance: 1,
+			maxMoves:  -1,
+			locked:    map[string]bool{"c": true},
+			endResult: map[string]string{"a": "1", "b": "3", "c": "1", "d": "2", "e": "2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := pair(startingResult, keys, values, options)
+			p = rebalance(p, values, options, test.tolerance, test.maxMoves, func(key string) bool { return !test.locked[key] })
+
+			endResult := map[string]attr.Value{}
+			for key, value := range test.endResult {
+				endResult[key] = basetypes.NewStringValue(value)
+			}
+
+			if actualResult := p.result(); !basetypes.NewMapValueMust(types.StringType, endResult).Equal(actualResult) {
+				t.Errorf("Got %+v, wanted %+v", actualResult, endResult)
+			}
+		})
+	}
+}
+
+func TestInternalPairTiers(t *testing.T) {
+	known := func(value string) basetypes.StringValue {
+		return basetypes.NewStringValue(value)
+	}
+
+	unknown := basetypes.NewStringUnknown()
+
+	options := pairOptions{
+		tiers:        map[string]int{"r1": 0, "r2": 0, "o1": 1, "o2": 1},
+		unknownTiers: []int{0},
+	}
+
+	var tests = []struct {
+		name           string
+		startingResult map[string]string
+		keys           []basetypes.StringValue
+		values         []basetypes.StringValue
+		options        pairOptions
+		endResult      map[string]attr.Value
+	}{
+		{
+			name:      "highest tier first",
+			keys:      []basetypes.StringValue{known("a"), known("b"), known("c")},
+			values:    []basetypes.StringValue{known("o1"), known("o2"), known("r1"), known("r2")},
+			options:   options,
+			endResult: map[string]attr.Value{"a": known("r1"), "b": known("r2"), "c": known("o1")},
+		},
+		{
+			name:           "existing kept",
+			startingResult: map[string]string{"a": "o1"},
+			keys:           []basetypes.StringValue{known("a"), known("b")},
+			values:         []basetypes.StringValue{known("r1"), known("r2"), known("o1")},
+			options:        options,
+			endResult:      map[string]attr.Value{"a": known("o1"), "b": known("r1")},
+		},
+		{
+			name:      "unknown in higher tier",
+			keys:      []basetypes.StringValue{known("a"), known("b")},
+			values:    []basetypes.StringValue{known("r1"), unknown, known("o1")},
+			options:   options,
+			endResult: map[string]attr.Value{"a": known("r1"), "b": unknown},
+		},
+		{
+			name:      "unknown in lower tier",
+			keys:      []basetypes.StringValue{known("a"), known("b"), known("c")},
+			values:    []basetypes.StringValue{known("r1"), known("o1"), unknown},
+			options:   pairOptions{tiers: options.tiers, unknownTiers: []int{1}},
+			endResult: map[string]attr.Value{"a": known("r1"), "b": known("o1"), "c": unknown},
+		},
+		{
+			name:           "least used first",
+			startingResult: map[string]string{"a": "r1"},
+			keys:           []basetypes.StringValue{known("a"), known("b"), known("c"), known("d")},
+			values:         []basetypes.StringValue{known("o1"), known("r1"), known("r2")},
+			options:        pairOptions{tiers: options.tiers, keysPerValue: 2},
+			endResult:      map[string]attr.Value{"a": known("r1"), "b": known("r2"), "c": known("r1"), "d": known("r2")},
+		},
+		{
+			name:      "excluded value skipped",
+			keys:      []basetypes.StringValue{known("a"), known("b")},
+			values:    []basetypes.StringValue{known("r1"), known("r2")},
+			options:   pairOptions{tiers: options.tiers, excluded: map[string]string{"a": "r1"}},
+			endResult: map[string]attr.Value{"a": known("r2"), "b": known("r1")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actualResult := pair(test.startingResult, test.keys, test.values, test.options).result()
+			endResult := basetypes.NewMapValueMust(types.StringType, test.endResult)
+
+			if !endResult.Equal(actualResult) {
+				t.Errorf("Got %+v, wanted %+v", actualResult, endResult)
+			}
+		})
+	}
+}
+
+func TestInternalPairMigrate(t *testing.T) {
+	values := []basetypes.StringValue{
+		basetypes.NewStringValue("r1"),
+		basetypes.NewStringValue("r2"),
+		basetypes.NewStringValue("s1"),
+		basetypes.NewStringValue("o1"),
+	}
+	keys := []basetypes.StringValue{
+		basetypes.NewStringValue("a"),
+		basetypes.NewStringValue("b"),
+		basetypes.NewStringValue("c"),
+	}
+	options := pairOptions{tiers: map[string]int{"r1": 0, "r2": 0, "s1": 1, "o1": 2}}
+	startingResult := map[string]string{"a": "r1", "b": "s1", "c": "o1"}
+
+	var tests = []struct {
+		name      string
+		maxMoves  int
+		locked    map[string]bool
+		endResult map[string]string
+	}{
+		{
+			name:      "lowest tier first",
+			maxMoves:  1,
+			endResult: map[string]string{"a": "r1", "b": "s1", "c": "r2"},
 		},
 		{
-			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-				basetypes.NewStringUnknown(),
-			},
-			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringUnknown(),
-				basetypes.NewStringValue("4"),
-			},
-			startingResult: map[string]string{},
-			endResult:      basetypes.NewMapUnknown(types.StringType),
+			name:      "unlimited",
+			maxMoves:  -1,
+			endResult: map[string]string{"a": "r1", "b": "s1", "c": "r2"},
 		},
-		// stable
 		{
-			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-			},
-			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-				basetypes.NewStringUnknown(),
-			},
-			startingResult: map[string]string{
-				"a": "1",
-				"b": "3",
-				"c": "2",
-			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("3"),
-				"c": basetypes.NewStringValue("2"),
-			}),
+			name:      "locked",
+			maxMoves:  -1,
+			locked:    map[string]bool{"c": true},
+			endResult: map[string]string{"a": "r1", "b": "r2", "c": "o1"},
 		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := pair(startingResult, keys, values, options)
+			p = migrate(p, values, options, test.maxMoves, func(key string) bool { return !test.locked[key] })
+
+			endResult := map[string]attr.Value{}
+			for key, value := range test.endResult {
+				endResult[key] = basetypes.NewStringValue(value)
+			}
+
+			if actualResult := p.result(); !basetypes.NewMapValueMust(types.StringType, endResult).Equal(actualResult) {
+				t.Errorf("Got %+v, wanted %+v", actualResult, endResult)
+			}
+		})
+	}
+}
+
+func TestInternalPairLocality(t *testing.T) {
+	locality := func(attributes ...string) []basetypes.StringValue {
+		values := make([]basetypes.StringValue, 0, len(attributes))
+		for _, attribute := range attributes {
+			values = append(values, basetypes.NewStringValue(attribute))
+		}
+
+		return values
+	}
+
+	keys := []basetypes.StringValue{basetypes.NewStringValue("a"), basetypes.NewStringValue("b")}
+	values := []basetypes.StringValue{
+		basetypes.NewStringValue("1"),
+		basetypes.NewStringValue("2"),
+		basetypes.NewStringValue("3"),
+		basetypes.NewStringValue("4"),
+	}
+	valueLocality := map[string][]basetypes.StringValue{
+		"1": locality("x", "r1"),
+		"2": locality("y", "r1"),
+		"3": locality("x", "r2"),
+		"4": locality("x", "r1"),
+	}
+
+	var tests = []struct {
+		name      string
+		locality  map[string][]basetypes.StringValue
+		endResult map[string]string
+	}{
 		{
-			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("a"),
-				basetypes.NewStringUnknown(),
-				basetypes.NewStringValue("c"),
-			},
-			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-			},
-			startingResult: map[string]string{
-				"a": "1",
-				"c": "2",
-			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"c": basetypes.NewStringValue("2"),
-			}),
+			name:      "none",
+			endResult: map[string]string{"a": "1", "b": "2"},
 		},
-		// stable - addition
 		{
-			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-				basetypes.NewStringUnknown(),
-			},
-			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-				basetypes.NewStringValue("4"),
-			},
-			startingResult: map[string]string{
-				"a": "1",
-				"b": "3",
-				"c": "2",
-			},
-			endResult: basetypes.NewMapUnknown(types.StringType),
+			name:      "all shared",
+			locality:  map[string][]basetypes.StringValue{"a": locality("x", "r1"), "b": locality("y", "r1")},
+			endResult: map[string]string{"a": "1", "b": "2"},
 		},
 		{
-			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-				basetypes.NewStringUnknown(),
-			},
-			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-			},
-			startingResult: map[string]string{
-				"a": "1",
-				"b": "3",
-				"c": "2",
-			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("3"),
-				"c": basetypes.NewStringValue("2"),
-			}),
+			name:      "leading shared",
+			locality:  map[string][]basetypes.StringValue{"a": locality("y", "r9"), "b": locality("x", "r2")},
+			endResult: map[string]string{"a": "2", "b": "3"},
 		},
 		{
-			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-				basetypes.NewStringValue("d"),
-			},
-			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-				basetypes.NewStringUnknown(),
-			},
-			startingResult: map[string]string{
-				"a": "1",
-				"b": "3",
-				"c": "2",
-			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("3"),
-				"c": basetypes.NewStringValue("2"),
-				"d": basetypes.NewStringUnknown(),
-			}),
+			name:      "only the first shared",
+			locality:  map[string][]basetypes.StringValue{"b": locality("x", "r1")},
+			endResult: map[string]string{"a": "1", "b": "4"},
 		},
 		{
-			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("a"),
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-			},
-			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-				basetypes.NewStringUnknown(),
-			},
-			startingResult: map[string]string{
-				"a": "1",
-				"b": "3",
-				"c": "2",
-			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"a": basetypes.NewStringValue("1"),
-				"b": basetypes.NewStringValue("3"),
-				"c": basetypes.NewStringValue("2"),
-			}),
+			name:      "nothing shared",
+			locality:  map[string][]basetypes.StringValue{"a": locality("z", "r1")},
+			endResult: map[string]string{"a": "1", "b": "2"},
 		},
-		// stable - addition & removal
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := pairOptions{locality: test.locality, valueLocality: valueLocality}
+			p := pair(map[string]string{}, keys, values, options)
+
+			endResult := map[string]attr.Value{}
+			for key, value := range test.endResult {
+				endResult[key] = basetypes.NewStringValue(value)
+			}
+
+			if actualResult := p.result(); !basetypes.NewMapValueMust(types.StringType, endResult).Equal(actualResult) {
+				t.Errorf("Got %+v, wanted %+v", actualResult, endResult)
+			}
+		})
+	}
+}
+
+func TestInternalPairUnassigned(t *testing.T) {
+	keys := []basetypes.StringValue{
+		basetypes.NewStringValue("A"),
+		basetypes.NewStringValue("B"),
+		basetypes.NewStringValue("C"),
+	}
+	values := []basetypes.StringValue{basetypes.NewStringValue("1")}
+
+	var tests = []struct {
+		name          string
+		options       pairOptions
+		endUnassigned []string
+	}{
 		{
-			keys: []basetypes.StringValue{
-				basetypes.NewStringValue("b"),
-				basetypes.NewStringValue("c"),
-				basetypes.NewStringValue("e"),
-			},
-			values: []basetypes.StringValue{
-				basetypes.NewStringValue("1"),
-				basetypes.NewStringValue("2"),
-				basetypes.NewStringValue("3"),
-				basetypes.NewStringUnknown(),
-			},
-			startingResult: map[string]string{
-				"a": "1",
-				"b": "3",
-				"c": "2",
-			},
-			endResult: basetypes.NewMapValueMust(types.StringType, map[string]attr.Value{
-				"b": basetypes.NewStringValue("3"),
-				"c": basetypes.NewStringValue("2"),
-				"e": basetypes.NewStringValue("1"),
-			}),
+			name:          "none",
+			endUnassigned: []string{"A", "B"},
+		},
+		{
+			name:          "normalized",
+			options:       pairOptions{normalization: normalizationHostname},
+			endUnassigned: []string{"A", "B"},
+		},
+		{
+			name:          "capacity",
+			options:       pairOptions{keysPerValue: 2},
+			endUnassigned: []string{"B"},
 		},
 	}
 
 	for _, test := range tests {
-		testname := fmt.Sprintf("%+v,%+v,%+v", test.keys, test.values, test.startingResult)
+		t.Run(test.name, func(t *testing.T) {
+			actualUnassigned := pair(map[string]string{"C": "1"}, keys, values, test.options).unassigned
+			sort.Strings(actualUnassigned)
 
-		t.Run(testname, func(t *testing.T) {
-			actualResult := pairStable(test.startingResult, test.keys, test.values)
+			if !reflect.DeepEqual(test.endUnassigned, actualUnassigned) {
+				t.Errorf("Got %+v, wanted %+v", actualUnassigned, test.endUnassigned)
+			}
+		})
+	}
+}
 
-			if !reflect.DeepEqual(test.endResult, actualResult) {
-				t.Errorf("Got %+v, wanted %+v", actualResult, test.endResult)
+func TestInternalParseImportID(t *testing.T) {
+	var tests = []struct {
+		id     string
+		result map[string]string
+		err    bool
+	}{
+		{id: `{"a":"1","b":"2"}`, result: map[string]string{"a": "1", "b": "2"}},
+		{id: `{}`, result: map[string]string{}},
+		{id: "a=1,b=2", result: map[string]string{"a": "1", "b": "2"}},
+		{id: " a = 1 , b=x=y", result: map[string]string{"a": "1", "b": "x=y"}},
+		{id: "a=", result: map[string]string{"a": ""}},
+		{id: `{"a":1}`, err: true},
+		{id: `{"a":"1"`, err: true},
+		{id: "", err: true},
+		{id: "a", err: true},
+		{id: "=1", err: true},
+		{id: "a=1,a=2", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.id, func(t *testing.T) {
+			result, err := parseImportID(test.id)
+
+			if test.err {
+				if err == nil {
+					t.Errorf("Got %v, wanted an error", result)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Got error %s", err)
+			}
+
+			if !reflect.DeepEqual(result, test.result) {
+				t.Errorf("Got %v, wanted %v", result, test.result)
+			}
+		})
+	}
+}
+
+func TestInternalValidTemplate(t *testing.T) {
+	var tests = []struct {
+		template string
+		valid    bool
+	}{
+		{"spare-%d", true},
+		{"host-%03d", true},
+		{"spare", false},
+		{"spare-%d-%d", false},
+		{"spare-%s", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.template, func(t *testing.T) {
+			if valid := validTemplate(test.template); valid != test.valid {
+				t.Errorf("Got %t, wanted %t", valid, test.valid)
 			}
 		})
 	}
@@ -714,3 +3494,108 @@ func convertAndCheck(name, address string, expectedValue map[string]string, valu
 
 	return err
 }
+
+// testPairModel returns a planned pairModel for the given keys, values and result with every other attribute
+// left unset or unknown.
+func testPairModel(keys, values []attr.Value, result types.Map) pairModel {
+	return pairModel{
+		AddedKeys:             types.SetUnknown(types.StringType),
+		ApprovedMoves:         types.SetNull(types.StringType),
+		AssignmentMetadata:    types.MapUnknown(types.ObjectType{AttrTypes: assignmentMetadataAttrTypes}),
+		Assignments:           types.SetUnknown(types.ObjectType{AttrTypes: assignmentAttrTypes}),
+		ChangePolicy:          types.StringNull(),
+		ChurnRatio:            types.Float64Unknown(),
+		FreedValues:           types.SetUnknown(types.StringType),
+		Generation:            types.Int64Unknown(),
+		Grouped:               types.MapUnknown(groupedType),
+		History:               types.ListUnknown(types.ObjectType{AttrTypes: historyAttrTypes}),
+		HistorySize:           types.Int64Value(defaultHistorySize),
+		ID:                    types.StringUnknown(),
+		InitialResult:         types.MapNull(types.StringType),
+		Inverse:               types.MapUnknown(types.StringType),
+		Keepers:               types.MapNull(types.StringType),
+		KeyAttributes:         types.ListNull(types.StringType),
+		KeyGenerator:          types.ObjectNull(generatorAttrTypes),
+		KeyObjects:            types.SetNull(resultObjectType),
+		KeyPattern:            types.StringNull(),
+		LocalityAttributes:    types.ListNull(types.StringType),
+		LockedKeys:            types.SetNull(types.StringType),
+		KeySeparator:          types.StringNull(),
+		Keys:                  types.SetValueMust(types.StringType, keys),
+		MaxKeysPerValue:       types.Int64Null(),
+		MigrateToHigherTiers:  types.BoolNull(),
+		Name:                  types.StringNull(),
+		Normalization:         types.StringNull(),
+		Ordered:               types.ListUnknown(types.ObjectType{AttrTypes: orderedAttrTypes}),
+		ReassignKeys:          types.MapNull(types.StringType),
+		Overflow:              types.StringNull(),
+		OverflowTemplate:      types.StringNull(),
+		RebalanceMaxMoves:     types.Int64Null(),
+		RebalanceTolerance:    types.Int64Null(),
+		RebalanceTrigger:      types.StringNull(),
+		Reassigned:            types.MapUnknown(types.ObjectType{AttrTypes: reassignmentAttrTypes}),
+		RemovedKeys:           types.SetUnknown(types.StringType),
+		Result:                result,
+		ResultKeyObjects:      types.MapUnknown(resultObjectType),
+		ResultObjects:         types.MapUnknown(resultObjectType),
+		RollbackToGeneration:  types.Int64Null(),
+		TierMigrationMaxMoves: types.Int64Null(),
+		UnassignedKeys:        types.SetUnknown(types.StringType),
+		ValueGenerator:        types.ObjectNull(generatorAttrTypes),
+		ValueObjects:          types.MapNull(resultObjectType),
+		ValuePattern:          types.StringNull(),
+		ValueTiers:            types.ListNull(types.SetType{ElemType: types.StringType}),
+		Values:                types.SetValueMust(types.StringType, values),
+	}
+}
+
+func testSchema(t *testing.T, r fwresource.Resource) fwresource.SchemaResponse {
+	t.Helper()
+
+	var resp fwresource.SchemaResponse
+	r.Schema(context.Background(), fwresource.SchemaRequest{}, &resp)
+
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("Unexpected schema diagnostics: %+v", resp.Diagnostics)
+	}
+
+	return resp
+}
+
+// testPlan returns a plan for r populated from model.
+func testPlan(t *testing.T, r fwresource.Resource, model interface{}) tfsdk.Plan {
+	t.Helper()
+
+	schema := testSchema(t, r).Schema
+	plan := tfsdk.Plan{
+		Raw:    tftypes.NewValue(schema.Type().TerraformType(context.Background()), nil),
+		Schema: schema,
+	}
+
+	if diags := plan.Set(context.Background(), model); diags.HasError() {
+		t.Fatalf("Unexpected plan diagnostics: %+v", diags)
+	}
+
+	return plan
+}
+
+// testState returns a state for r populated from model, or a null state when model is nil.
+func testState(t *testing.T, r fwresource.Resource, model interface{}) tfsdk.State {
+	t.Helper()
+
+	schema := testSchema(t, r).Schema
+	state := tfsdk.State{
+		Raw:    tftypes.NewValue(schema.Type().TerraformType(context.Background()), nil),
+		Schema: schema,
+	}
+
+	if model == nil {
+		return state
+	}
+
+	if diags := state.Set(context.Background(), model); diags.HasError() {
+		t.Fatalf("Unexpected state diagnostics: %+v", diags)
+	}
+
+	return state
+}
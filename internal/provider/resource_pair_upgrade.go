@@ -0,0 +1,190 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+)
+
+// pairStateUpgrades holds, for each prior schema version, the step that upgrades a raw state of that version to
+// the next one. A state is upgraded by every step from its version on, so a new schema version only needs a step
+// for what changed since the last one. Attributes added since are left out, which decodes them as null.
+var pairStateUpgrades = []func(context.Context, map[string]json.RawMessage) error{
+	upgradePairStateV0,
+	upgradePairStateV1,
+}
+
+func (r *PairResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	upgraders := make(map[int64]resource.StateUpgrader, len(pairStateUpgrades))
+	for version := range pairStateUpgrades {
+		upgraders[int64(version)] = resource.StateUpgrader{
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				upgradePairState(ctx, version, req, resp)
+			},
+		}
+	}
+
+	return upgraders
+}
+
+// upgradePairState upgrades a raw state of the given schema version through each of the following steps.
+func upgradePairState(ctx context.Context, version int, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+	var state map[string]json.RawMessage
+	if req.RawState == nil || json.Unmarshal(req.RawState.JSON, &state) != nil {
+		resp.Diagnostics.AddError(
+			"Unable to Upgrade Resource State",
+			fmt.Sprintf("The saved state of schema version %d could not be read.", version),
+		)
+		return
+	}
+
+	for i, upgrade := range pairStateUpgrades[version:] {
+		if err := upgrade(ctx, state); err != nil {
+			resp.Diagnostics.AddError(
+				"Unable to Upgrade Resource State",
+				fmt.Sprintf("The state could not be upgraded from schema version %d: %s.", version+i, err),
+			)
+			return
+		}
+	}
+
+	upgraded, err := json.Marshal(state)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unable to Upgrade Resource State",
+			fmt.Sprintf("The upgraded state could not be written: %s.", err),
+		)
+		return
+	}
+
+	resp.DynamicValue = &tfprotov6.DynamicValue{JSON: upgraded}
+}
+
+// decodeRaw decodes the named attribute of a raw state into target, leaving it as is when the attribute is null
+// or missing, as it is in states written before the attribute existed.
+func decodeRaw(state map[string]json.RawMessage, name string, target any) error {
+	raw, ok := state[name]
+	if !ok {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, target); err != nil {
+		return fmt.Errorf("%s cannot be read: %w", name, err)
+	}
+
+	return nil
+}
+
+// encodeRaw sets the attributes of a raw state to the encoding of each of values.
+func encodeRaw(state map[string]json.RawMessage, values map[string]any) error {
+	for name, value := range values {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("%s cannot be written: %w", name, err)
+		}
+
+		state[name] = encoded
+	}
+
+	return nil
+}
+
+// upgradePairStateV0 starts tracking assignment metadata and history, treating every existing assignment as
+// made in the first generation at an unknown time, and lists the configured keys left without a value. States
+// of the first releases only had id, keys, values and result.
+func upgradePairStateV0(ctx context.Context, state map[string]json.RawMessage) error {
+	var result map[string]string
+	if err := decodeRaw(state, "result", &result); err != nil {
+		return err
+	}
+
+	metadata := make(map[string]any, len(result))
+	for key, value := range result {
+		metadata[key] = map[string]any{
+			"assigned_at": nil,
+			"generation":  1,
+			"hash":        assignmentHash(key, value),
+		}
+	}
+
+	// Every configured key that is not in the result was left without a value.
+	var keys []string
+	if err := decodeRaw(state, "keys", &keys); err != nil {
+		return err
+	}
+
+	var keyObjects []map[string]string
+	if err := decodeRaw(state, "key_objects", &keyObjects); err != nil {
+		return err
+	}
+
+	if keyObjects != nil {
+		var attributes []string
+		if err := decodeRaw(state, "key_attributes", &attributes); err != nil {
+			return err
+		}
+
+		var separator *string
+		if err := decodeRaw(state, "key_separator", &separator); err != nil {
+			return err
+		}
+
+		objects, diags := types.SetValueFrom(ctx, types.MapType{ElemType: types.StringType}, keyObjects)
+		if diags.HasError() {
+			return fmt.Errorf("key_objects cannot be read")
+		}
+
+		attributesValue, diags := types.ListValueFrom(ctx, types.StringType, attributes)
+		if diags.HasError() {
+			return fmt.Errorf("key_attributes cannot be read")
+		}
+
+		model := pairModel{
+			KeyAttributes: attributesValue,
+			KeyObjects:    objects,
+			KeySeparator:  types.StringPointerValue(separator),
+		}
+
+		keys = nil
+		identified, _ := model.keyObjects()
+		for _, object := range identified {
+			keys = append(keys, object.key)
+		}
+	}
+
+	unassigned := make([]string, 0)
+	for _, key := range keys {
+		if _, ok := result[key]; !ok {
+			unassigned = append(unassigned, key)
+		}
+	}
+
+	// The existing result starts the history so that it can be rolled back to.
+	return encodeRaw(state, map[string]any{
+		"assignment_metadata": metadata,
+		"generation":          1,
+		"history":             []any{map[string]any{"generation": 1, "result": result}},
+		"history_size":        defaultHistorySize,
+		"unassigned_keys":     unassigned,
+	})
+}
+
+// upgradePairStateV1 replaces the static id of schema version 1 with the hash of the result, as that of a pair
+// without a name. The first result may no longer be in history, so the current one is used.
+func upgradePairStateV1(ctx context.Context, state map[string]json.RawMessage) error {
+	var result map[string]string
+	if err := decodeRaw(state, "result", &result); err != nil {
+		return err
+	}
+
+	return encodeRaw(state, map[string]any{
+		"id": resultHash(result),
+	})
+}
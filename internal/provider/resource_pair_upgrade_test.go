@@ -0,0 +1,149 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestResourcePairUpgradeStateVersions(t *testing.T) {
+	schema := testSchema(t, NewPairResource()).Schema
+
+	// Every prior schema version needs a step to the next one.
+	if int64(len(pairStateUpgrades)) != schema.Version {
+		t.Errorf("Got %d upgrade steps, wanted one for each of the %d prior schema versions", len(pairStateUpgrades), schema.Version)
+	}
+}
+
+func TestResourcePairUpgradeStateV0(t *testing.T) {
+	// A state as written by the first releases, before most attributes existed.
+	attributes := testUpgradedState(t, 0, `{"id":"-","keys":["a","b","c"],"values":["1","2"],"result":{"a":"2","b":"1"}}`)
+
+	var generation big.Float
+	if err := attributes["generation"].As(&generation); err != nil || generation.Cmp(big.NewFloat(1)) != 0 {
+		t.Errorf("Got generation %v, wanted 1", attributes["generation"])
+	}
+
+	var metadata map[string]tftypes.Value
+	if err := attributes["assignment_metadata"].As(&metadata); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(metadata) != 2 {
+		t.Fatalf("Got metadata %v, wanted an entry for each key", metadata)
+	}
+
+	var entry map[string]tftypes.Value
+	if err := metadata["a"].As(&entry); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var hash string
+	if err := entry["hash"].As(&hash); err != nil || hash != assignmentHash("a", "2") {
+		t.Errorf("Got hash %v, wanted %s", entry["hash"], assignmentHash("a", "2"))
+	}
+
+	if !entry["assigned_at"].IsNull() {
+		t.Errorf("Got assigned_at %v, wanted null", entry["assigned_at"])
+	}
+
+	var result map[string]tftypes.Value
+	if err := attributes["result"].As(&result); err != nil || len(result) != 2 {
+		t.Errorf("Got result %v, wanted it kept", attributes["result"])
+	}
+
+	var history []tftypes.Value
+	if err := attributes["history"].As(&history); err != nil || len(history) != 1 {
+		t.Errorf("Got history %v, wanted the existing result as its only entry", attributes["history"])
+	}
+
+	var unassigned []tftypes.Value
+	if err := attributes["unassigned_keys"].As(&unassigned); err != nil || len(unassigned) != 1 || !unassigned[0].Equal(tftypes.NewValue(tftypes.String, "c")) {
+		t.Errorf("Got unassigned_keys %v, wanted c", attributes["unassigned_keys"])
+	}
+
+	// Later steps of the chain apply too.
+	var id string
+	if err := attributes["id"].As(&id); err != nil || id != resultHash(map[string]string{"a": "2", "b": "1"}) {
+		t.Errorf("Got id %v, wanted the hash of the result", attributes["id"])
+	}
+}
+
+func TestResourcePairUpgradeStateV0KeyObjects(t *testing.T) {
+	attributes := testUpgradedState(t, 0, `{"id":"-","key_objects":[{"host":"a","zone":"x"},{"host":"b","zone":"y"}],"key_attributes":["host","zone"],"key_separator":null,"values":["1"],"result":{"a/x":"1"}}`)
+
+	var unassigned []tftypes.Value
+	if err := attributes["unassigned_keys"].As(&unassigned); err != nil || len(unassigned) != 1 || !unassigned[0].Equal(tftypes.NewValue(tftypes.String, "b/y")) {
+		t.Errorf("Got unassigned_keys %v, wanted b/y", attributes["unassigned_keys"])
+	}
+}
+
+func TestResourcePairUpgradeStateV1(t *testing.T) {
+	// A state from before ids were derived from the name or result.
+	attributes := testUpgradedState(t, 1, `{"id":"-","keys":["a","b"],"values":["1","2","3"],"result":{"a":"2","b":"1"},"generation":3,"history_size":10}`)
+
+	var id string
+	if err := attributes["id"].As(&id); err != nil || id != resultHash(map[string]string{"a": "2", "b": "1"}) {
+		t.Errorf("Got id %v, wanted the hash of the result", attributes["id"])
+	}
+
+	if !attributes["name"].IsNull() {
+		t.Errorf("Got name %v, wanted null", attributes["name"])
+	}
+
+	var generation big.Float
+	if err := attributes["generation"].As(&generation); err != nil || generation.Cmp(big.NewFloat(3)) != 0 {
+		t.Errorf("Got generation %v, wanted it kept", attributes["generation"])
+	}
+
+	var result map[string]tftypes.Value
+	if err := attributes["result"].As(&result); err != nil || len(result) != 2 {
+		t.Errorf("Got result %v, wanted it kept", attributes["result"])
+	}
+}
+
+// testUpgradedState returns the attributes of a raw state of the given schema version once upgraded.
+func testUpgradedState(t *testing.T, version int64, state string) map[string]tftypes.Value {
+	t.Helper()
+
+	server, err := providerserver.NewProtocol6WithError(New("test")())()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	resp, err := server.UpgradeResourceState(context.Background(), &tfprotov6.UpgradeResourceStateRequest{
+		TypeName: "stablepairer_pair",
+		Version:  version,
+		RawState: &tfprotov6.RawState{
+			JSON: []byte(state),
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, diagnostic := range resp.Diagnostics {
+		t.Fatalf("Unexpected diagnostic: %s: %s", diagnostic.Summary, diagnostic.Detail)
+	}
+
+	schema := testSchema(t, NewPairResource()).Schema
+
+	upgraded, err := resp.UpgradedState.Unmarshal(schema.Type().TerraformType(context.Background()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	var attributes map[string]tftypes.Value
+	if err := upgraded.As(&attributes); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return attributes
+}
@@ -0,0 +1,324 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+)
+
+var (
+	_ resource.ResourceWithModifyPlan     = (*SensitivePairResource)(nil)
+	_ resource.ResourceWithValidateConfig = (*SensitivePairResource)(nil)
+)
+
+func NewSensitivePairResource() resource.Resource {
+	return &SensitivePairResource{}
+}
+
+type SensitivePairResource struct{}
+
+func (r *SensitivePairResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var model sensitivePairModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &model)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	model.ID = types.StringValue("-")
+	model.Salt = types.StringValue(rand.Text())
+
+	r.modify(ctx, model, req.Config, map[string]string{}, &resp.Diagnostics, &resp.State)
+}
+
+// Delete does not need to explicitly call resp.State.RemoveResource() as this is automatically handled by the
+// [framework](https://github.com/hashicorp/terraform-plugin-framework/pull/301).
+func (r *SensitivePairResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+}
+
+func (r *SensitivePairResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_sensitive_pair"
+}
+
+func (r *SensitivePairResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	// Will be when the resource is being deleted.
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var model sensitivePairModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &model)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Read existing result_ids field from state, if present.
+	existingResultIDs := make(map[string]string)
+	if !req.State.Raw.IsNull() {
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("result_ids"), &existingResultIDs)...)
+
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	r.modify(ctx, model, req.Config, existingResultIDs, &resp.Diagnostics, &resp.Plan)
+}
+
+// Read does not need to perform any operations as the state in ReadResourceResponse is already populated.
+func (r *SensitivePairResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+}
+
+func (r *SensitivePairResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		MarkdownDescription: "Generates a stable mapping of keys to secret values. Works like `stablepairer_pair`, except that values are never shown in plan output. With `values` the values and result are still stored in state as is, so only `values_wo` keeps them out of state, which then only tracks salted hashes of them for stability.",
+		Attributes: map[string]schema.Attribute{
+			"keys": schema.SetAttribute{
+				Description: "The set of keys to assign a value. An unknown key that can be assigned a value (either known or unknown) will trigger the result to be unknown.",
+				ElementType: types.StringType,
+				Required:    true,
+			},
+			"values": schema.SetAttribute{
+				Description: "The set of values to assign to keys. Exactly one of values or values_wo must be set.",
+				ElementType: types.StringType,
+				Optional:    true,
+				Sensitive:   true,
+			},
+			"values_wo": schema.ListAttribute{
+				Description: "The values to assign to keys, which are never stored in state. Each value must be unique. When set, result is left empty and only result_ids is populated. Exactly one of values or values_wo must be set.",
+				ElementType: types.StringType,
+				Optional:    true,
+				WriteOnly:   true,
+			},
+
+			// Computed
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "A static value used internally by Terraform, this should not be referenced in configurations.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"result": schema.MapAttribute{
+				Computed:    true,
+				Description: "The stable mapping of keys to values, size will be the smaller of the size of keys and values. Only populated when values is set.",
+				ElementType: types.StringType,
+				Sensitive:   true,
+			},
+			"result_ids": schema.MapAttribute{
+				Computed:    true,
+				Description: "The stable mapping of keys to redacted identifiers of their values, which is what shows up in plan output. The identifier of a value is `sha256(\"${salt}:${value}\")`.",
+				ElementType: types.StringType,
+			},
+			"salt": schema.StringAttribute{
+				Computed:    true,
+				Description: "The random salt used to derive the identifiers in result_ids, generated when the resource is created.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+// Update ensures the plan value is copied to the state to complete the update.
+func (r *SensitivePairResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var model sensitivePairModel
+
+	// Read Terraform plan data into the model
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &model)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Read existing result_ids field from state.
+	existingResultIDs := make(map[string]string)
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("result_ids"), &existingResultIDs)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	r.modify(ctx, model, req.Config, existingResultIDs, &resp.Diagnostics, &resp.State)
+}
+
+func (r *SensitivePairResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var model sensitivePairModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &model)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if model.Values.IsNull() == model.ValuesWO.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("values"),
+			"Invalid Attribute Combination",
+			"Exactly one of values or values_wo must be set.",
+		)
+		return
+	}
+
+	if model.ValuesWO.IsNull() || model.ValuesWO.IsUnknown() {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for i, value := range model.ValuesWO.Elements() {
+		value, ok := value.(types.String)
+		if !ok || value.IsUnknown() || value.IsNull() {
+			continue
+		}
+
+		if seen[value.ValueString()] {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("values_wo").AtListIndex(i),
+				"Duplicate Value",
+				"Each element of values_wo must be unique.",
+			)
+		}
+
+		seen[value.ValueString()] = true
+	}
+}
+
+func (r *SensitivePairResource) modify(ctx context.Context, model sensitivePairModel, config tfsdk.Config, existingResultIDs map[string]string, diagnostics *diag.Diagnostics, state PlanOrState) {
+	writeOnly := model.Values.IsNull()
+
+	values := model.Values
+	if writeOnly {
+		var valuesWO types.List
+		diagnostics.Append(config.GetAttribute(ctx, path.Root("values_wo"), &valuesWO)...)
+		if diagnostics.HasError() {
+			return
+		}
+
+		if valuesWO.IsUnknown() {
+			values = types.SetUnknown(types.StringType)
+		} else {
+			var diags diag.Diagnostics
+			values, diags = types.SetValue(types.StringType, valuesWO.Elements())
+			diagnostics.Append(diags...)
+			if diagnostics.HasError() {
+				return
+			}
+		}
+	}
+
+	// Nothing can be paired until both sets are at least partially known.
+	if model.Keys.IsUnknown() || values.IsUnknown() {
+		model.Result = types.MapUnknown(types.StringType)
+		model.ResultIDs = types.MapUnknown(types.StringType)
+
+		if writeOnly {
+			model.Result = types.MapNull(types.StringType)
+		}
+
+		diagnostics.Append(state.Set(ctx, model)...)
+		return
+	}
+
+	keys := make([]basetypes.StringValue, len(model.Keys.Elements()))
+	diagnostics.Append(model.Keys.ElementsAs(ctx, &keys, false)...)
+	if diagnostics.HasError() {
+		return
+	}
+
+	rawValues := make([]basetypes.StringValue, len(values.Elements()))
+	diagnostics.Append(values.ElementsAs(ctx, &rawValues, false)...)
+	if diagnostics.HasError() {
+		return
+	}
+
+	// Values are paired by their identifiers so that state never needs to hold them. The salt is only unknown
+	// when planning a create, in which case there is nothing to keep stable and pairing the values themselves
+	// hands them out in the same order.
+	ids := rawValues
+	valuesByID := make(map[string]basetypes.StringValue, len(rawValues))
+
+	if !model.Salt.IsUnknown() {
+		ids = make([]basetypes.StringValue, len(rawValues))
+
+		for i, value := range rawValues {
+			ids[i] = value
+
+			if !value.IsUnknown() {
+				ids[i] = basetypes.NewStringValue(redactedID(model.Salt.ValueString(), value.ValueString()))
+			}
+
+			valuesByID[ids[i].ValueString()] = value
+		}
+	}
+
+	resultIDs := pairStable(existingResultIDs, keys, ids)
+
+	model.Result = types.MapNull(types.StringType)
+	model.ResultIDs = resultIDs
+
+	if model.Salt.IsUnknown() {
+		model.ResultIDs = types.MapUnknown(types.StringType)
+	}
+
+	if !writeOnly {
+		model.Result = resultIDs
+
+		if !model.Salt.IsUnknown() && !resultIDs.IsUnknown() {
+			result := make(map[string]attr.Value, len(resultIDs.Elements()))
+
+			for key, id := range resultIDs.Elements() {
+				result[key] = id
+
+				if id, ok := id.(basetypes.StringValue); ok && !id.IsUnknown() {
+					result[key] = valuesByID[id.ValueString()]
+				}
+			}
+
+			model.Result = types.MapValueMust(types.StringType, result)
+		}
+	}
+
+	diagnostics.Append(state.Set(ctx, model)...)
+	if diagnostics.HasError() {
+		return
+	}
+}
+
+type sensitivePairModel struct {
+	ID        types.String `tfsdk:"id"`
+	Keys      types.Set    `tfsdk:"keys"`
+	Result    types.Map    `tfsdk:"result"`
+	ResultIDs types.Map    `tfsdk:"result_ids"`
+	Salt      types.String `tfsdk:"salt"`
+	Values    types.Set    `tfsdk:"values"`
+	ValuesWO  types.List   `tfsdk:"values_wo"`
+}
+
+// redactedID returns the identifier that stands in for value in state and plan output.
+func redactedID(salt, value string) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s:%s", salt, value))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,161 @@
+// Copyright (c) Persona
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccResourceSensitivePair(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_sensitive_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result.%", "3"),
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result.b", "2"),
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result.c", "3"),
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result_ids.%", "3"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "a", "1"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "b", "2"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "c", "3"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_sensitive_pair" "test" {
+					keys   = ["a", "b", "c"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				PlanOnly: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_sensitive_pair" "test" {
+					keys   = ["a", "c", "d"]
+					values = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result.%", "3"),
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result.a", "1"),
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result.c", "3"),
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result.d", "2"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "d", "2"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceSensitivePairWriteOnly(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_11_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_sensitive_pair" "test" {
+					keys      = ["a", "b", "c"]
+					values_wo = ["4", "3", "4"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Duplicate Value`),
+			},
+			{
+				Config: `
+				resource "stablepairer_sensitive_pair" "test" {
+					keys      = ["a", "b", "c"]
+					values_wo = ["1", "2", "3"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("stablepairer_sensitive_pair.test", "result.%"),
+					resource.TestCheckNoResourceAttr("stablepairer_sensitive_pair.test", "values_wo.#"),
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result_ids.%", "3"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "a", "1"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "b", "2"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "c", "3"),
+				),
+			},
+			{
+				Config: `
+				resource "stablepairer_sensitive_pair" "test" {
+					keys      = ["a", "b", "c"]
+					values_wo = ["1", "2", "3"]
+				}
+				`,
+				PlanOnly: true,
+			},
+			{
+				Config: `
+				resource "stablepairer_sensitive_pair" "test" {
+					keys      = ["a", "b", "c"]
+					values_wo = ["4", "3", "1"]
+				}
+				`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("stablepairer_sensitive_pair.test", "result_ids.%", "3"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "a", "1"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "b", "4"),
+					testCheckRedactedID("stablepairer_sensitive_pair.test", "c", "3"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccResourceSensitivePairInvalidConfig(t *testing.T) {
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: map[string]func() (tfprotov6.ProviderServer, error){
+			"stablepairer": providerserver.NewProtocol6WithError(New("test")()),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+				resource "stablepairer_sensitive_pair" "test" {
+					keys = ["a", "b", "c"]
+				}
+				`,
+				ExpectError: regexp.MustCompile(`Exactly one of values or values_wo must be set`),
+			},
+		},
+	})
+}
+
+// testCheckRedactedID checks that key is assigned the identifier of value in result_ids.
+func testCheckRedactedID(name, key, value string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("not found: %s", name)
+		}
+
+		expected := redactedID(rs.Primary.Attributes["salt"], value)
+
+		return resource.TestCheckResourceAttr(name, "result_ids."+key, expected)(s)
+	}
+}